@@ -0,0 +1,207 @@
+package codegen
+
+import (
+	"excel-tools/export"
+	"excel-tools/types"
+	"excel-tools/util"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// identifier 合法的标识符，生成的类型名和字段名都需要满足
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Options 代码生成选项
+type Options struct {
+	// 生成代码的输出目录
+	Output string
+	// 包名/命名空间
+	Package string
+	// 所有枚举定义
+	Enums []*types.Enum
+	// 枚举类型是否导出为名称，否则导出为数值
+	EnumName bool
+	// 所有自定义结构体
+	Structs []*types.Struct
+}
+
+// enum 按名称查找枚举定义
+func (o *Options) enum(name string) *types.Enum {
+	for _, enum := range o.Enums {
+		if enum.Name == name {
+			return enum
+		}
+	}
+	return nil
+}
+
+// structType 按名称查找自定义结构体，不是自定义结构体时返回nil
+func (o *Options) structType(name string) *types.Struct {
+	for _, s := range o.Structs {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// structsUse 自定义结构体(包括点号分隔的字段名生成的结构体)的字段中是否用到了某个基础类型，例如pair
+func (o *Options) structsUse(base string) bool {
+	used := false
+	for _, s := range o.Structs {
+		for _, field := range s.Fields {
+			types.Walk(field.Type, func(t string) {
+				used = used || t == base
+			})
+		}
+	}
+	return used
+}
+
+// checkTypes 检查枚举和自定义结构体的名称以及成员名称能否作为标识符，并且类型名称不能和表名冲突，不同的表不能生成同名的类型
+func checkTypes(options *Options, tables []*Table) error {
+	for i, table := range tables {
+		for _, other := range tables[:i] {
+			if util.CamelCase(other.Name) == util.CamelCase(table.Name) {
+				return fmt.Errorf("tables %s and %s generate the same type %s", other.Name, table.Name, util.CamelCase(table.Name))
+			}
+		}
+	}
+	checkName := func(kind string, source string) error {
+		name := util.CamelCase(source)
+		if err := checkIdentifier(kind, source, name); err != nil {
+			return err
+		}
+		for _, table := range tables {
+			if util.CamelCase(table.Name) == name {
+				return fmt.Errorf("%s %s has the same name as table %s", kind, source, table.Name)
+			}
+		}
+		return nil
+	}
+	for _, enum := range options.Enums {
+		if err := checkName("enum", enum.Name); err != nil {
+			return err
+		}
+		for _, v := range enum.Values {
+			if err := checkIdentifier("enum value", v.Name, v.Name); err != nil {
+				return fmt.Errorf("enum %s: %w", enum.Name, err)
+			}
+		}
+	}
+	defined := make(map[string]bool)
+	for _, s := range options.Structs {
+		if err := checkName("type", s.Name); err != nil {
+			return err
+		}
+		if defined[util.CamelCase(s.Name)] {
+			return fmt.Errorf("type %s is defined more than once", s.Name)
+		}
+		defined[util.CamelCase(s.Name)] = true
+		for _, field := range s.Fields {
+			fieldName := util.CamelCase(field.Name)
+			if err := checkIdentifier("field", field.Name, fieldName); err != nil {
+				return fmt.Errorf("type %s: %w", s.Name, err)
+			}
+			// C#不允许成员和所在的类同名
+			if fieldName == util.CamelCase(s.Name) {
+				return fmt.Errorf("type %s: field %q has the same name as the type", s.Name, field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Table 导出表的结构信息
+type Table struct {
+	// 表名，默认为sheet名称，同时也是导出数据的文件名
+	Name string
+	// 客户端字段，按列顺序
+	Client []export.Field
+	// 服务端字段，按列顺序
+	Server []export.Field
+	// 主键字段
+	Key string
+	// 是否以主键作为键导出对象
+	Keyed bool
+	// 点号分隔的字段名生成的结构体，代码生成时通过Options.Structs统一生成
+	Structs []*types.Struct
+}
+
+// keyField 以主键作为键导出时的主键字段，不是按主键导出时返回false
+func (t *Table) keyField(fields []export.Field) (export.Field, bool) {
+	if !t.Keyed {
+		return export.Field{}, false
+	}
+	for _, field := range fields {
+		if field.Name == t.Key {
+			return field, true
+		}
+	}
+	return export.Field{}, false
+}
+
+// Generator 代码生成接口，在所有sheet解析完成后调用一次
+type Generator interface {
+	Generate(options *Options, tables []*Table) error
+}
+
+// checkFiles 检查每个表生成的文件不重复，也不和公共文件(例如enums.go)重名，文件名不区分大小写比较
+func checkFiles(tables []*Table, fileName func(table *Table) string, common ...string) error {
+	// 文件名以及生成该文件的表，公共文件为空
+	files := make(map[string]string)
+	for _, name := range common {
+		files[strings.ToLower(name)] = ""
+	}
+	for _, table := range tables {
+		name := fileName(table)
+		if other, ok := files[strings.ToLower(name)]; ok {
+			if other == "" {
+				return fmt.Errorf("table %s: generated file %s conflicts with the common file", table.Name, name)
+			}
+			return fmt.Errorf("tables %s and %s generate the same file %s", other, table.Name, name)
+		}
+		files[strings.ToLower(name)] = table.Name
+	}
+	return nil
+}
+
+// writeFile 写出生成的代码文件
+func writeFile(dir string, name string, data []byte) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name), data, os.ModePerm)
+}
+
+// checkIdentifier 检查生成的名称是否合法
+func checkIdentifier(kind string, source string, name string) error {
+	if !identifier.MatchString(name) {
+		return fmt.Errorf("%s %q can not be converted to a valid identifier", kind, source)
+	}
+	return nil
+}
+
+type GeneratorFactory struct {
+}
+
+// GetGenerator 使用 GeneratorFactory获得代码生成对象
+func (*GeneratorFactory) GetGenerator(lang string) (generator Generator, err error) {
+	switch lang {
+	case "go":
+		generator = new(GoGenerator)
+	case "csharp":
+		generator = new(CSharpGenerator)
+	case "typescript":
+		generator = new(TypeScriptGenerator)
+	case "jsonschema":
+		generator = new(JsonSchemaGenerator)
+	default:
+		err = fmt.Errorf("no such code generator for %s", lang)
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package codegen
+
+import (
+	"excel-tools/export"
+	"excel-tools/types"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testOptions 测试用的枚举和自定义结构体，注释包含换行
+func testOptions(t *testing.T) *Options {
+	t.Helper()
+	return &Options{
+		Output: t.TempDir(),
+		Enums: []*types.Enum{{Name: "Quality", Values: []*types.EnumValue{
+			{Name: "Common", Value: 1, Comment: "普通\n第二行"},
+			{Name: "Epic", Value: 4},
+		}}},
+		Structs: []*types.Struct{
+			{Name: "Reward", Comment: "奖励\n第二行", Fields: []*types.StructField{
+				{Name: "item", Type: "int"},
+				{Name: "count", Type: "long"},
+			}},
+			// 点号分隔的字段名 reward.p 生成的结构体，只有这里用到pair
+			{Name: "HeroBonus", Nested: true, Fields: []*types.StructField{
+				{Name: "p", Type: "pair"},
+				{Name: "rate", Type: "float", Nullable: true},
+			}},
+		},
+	}
+}
+
+// testTables 测试用的表，hero按数组导出，shop以主键作为键导出
+func testTables() []*Table {
+	hero := []export.Field{
+		{Name: "id", Type: "int", Note: "编号\n第二行"},
+		{Name: "name", Type: "string"},
+		{Name: "quality", Type: "enum<Quality>"},
+		{Name: "level", Type: "int", Nullable: true},
+		{Name: "bonus", Type: "HeroBonus"},
+		{Name: "rewards", Type: "Reward[]"},
+		{Name: "attrs", Type: "map<int,float>"},
+		{Name: "values", Type: "array"},
+		{Name: "ref", Type: "ref<shop.id>"},
+	}
+	shop := []export.Field{
+		{Name: "id", Type: "int"},
+		{Name: "price", Type: "long"},
+		{Name: "tags", Type: "string[]"},
+	}
+	return []*Table{
+		{Name: "hero", Client: hero, Server: hero, Key: "id"},
+		{Name: "shop", Client: shop, Server: shop, Key: "id", Keyed: true},
+	}
+}
+
+// readGenerated 读取生成的文件
+func readGenerated(t *testing.T, dir string, name string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// spaces 连续的空白，比较时忽略gofmt等对齐产生的空白差异
+var spaces = regexp.MustCompile(`[ \t]+`)
+
+// expectContains 检查生成的内容包含每一行，连续的空格和制表符按一个空格比较
+func expectContains(t *testing.T, name string, content string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(spaces.ReplaceAllString(content, " "), spaces.ReplaceAllString(line, " ")) {
+			t.Errorf("%s does not contain %q:\n%s", name, line, content)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package codegen
+
+import (
+	"bytes"
+	"excel-tools/types"
+	"excel-tools/util"
+	"fmt"
+	"strings"
+)
+
+type CSharpGenerator struct{}
+
+// Generate 生成Unity客户端使用的C#类，只包含客户端字段，JSON反序列化使用Newtonsoft.Json
+//
+// 每个表生成一个 <Table>.cs 文件，包含行数据类和 <Table>Table 容器类，另外生成 Tables.cs 提供 Load 一次读取客户端导出目录下的所有表。
+// 存在枚举定义时生成 Enums.cs，枚举导出为名称时字段使用 StringEnumConverter 反序列化，存在自定义结构体时生成 Structs.cs。
+func (*CSharpGenerator) Generate(options *Options, tables []*Table) error {
+	namespace := options.Package
+	if namespace == "" {
+		namespace = "Config"
+	}
+	if err := checkTypes(options, tables); err != nil {
+		return err
+	}
+	csharpFile := func(table *Table) string { return util.CamelCase(table.Name) + ".cs" }
+	if err := checkFiles(tables, csharpFile, "Enums.cs", "Structs.cs", "Tables.cs"); err != nil {
+		return err
+	}
+	if len(options.Enums) > 0 {
+		if err := writeFile(options.Output, "Enums.cs", csharpEnums(options, namespace)); err != nil {
+			return err
+		}
+	}
+	if len(options.Structs) > 0 {
+		data, err := csharpStructs(options, namespace)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(options.Output, "Structs.cs", data); err != nil {
+			return err
+		}
+	}
+	var (
+		// 自定义结构体中用到的pair/triple也需要生成对应的类型
+		usePair   = options.structsUse("pair")
+		useTriple = options.structsUse("triple")
+		loaded    []*Table
+	)
+	for _, table := range tables {
+		if len(table.Client) == 0 {
+			continue
+		}
+		name := util.CamelCase(table.Name)
+		if err := checkIdentifier("table", table.Name, name); err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+		writeCSharpHeader(&b, namespace)
+		writeCSharpSummary(&b, 1, table.Name+" 表的一行数据")
+		fmt.Fprintf(&b, "    public class %s\n    {\n", name)
+		for i, field := range table.Client {
+			fieldName := util.CamelCase(field.Name)
+			if err := checkIdentifier("field", field.Name, fieldName); err != nil {
+				return fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			// C#不允许成员和所在的类同名
+			if fieldName == name {
+				return fmt.Errorf("table %s: field %q has the same name as the class", table.Name, field.Name)
+			}
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			if field.Note != "" {
+				writeCSharpSummary(&b, 2, field.Note)
+			}
+			if err := writeCSharpField(&b, options, field.Name, field.Type, field.Nullable); err != nil {
+				return fmt.Errorf("table %s: field %s: %w", table.Name, field.Name, err)
+			}
+			types.Walk(field.Type, func(t string) {
+				usePair = usePair || t == "pair"
+				useTriple = useTriple || t == "triple"
+			})
+		}
+		b.WriteString("    }\n\n")
+
+		writeCSharpSummary(&b, 1, table.Name+" 表数据，对应 "+table.Name+".json")
+		fmt.Fprintf(&b, "    public class %sTable\n    {\n", name)
+		fmt.Fprintf(&b, "        public const string FileName = %q;\n\n", table.Name+".json")
+		if key, ok := table.keyField(table.Client); ok {
+			// 以主键作为键导出的表读取为字典
+			keyType, err := csharpType(options, key.Type)
+			if err != nil {
+				return fmt.Errorf("table %s: field %s: %w", table.Name, key.Name, err)
+			}
+			rowsType := fmt.Sprintf("Dictionary<%s, %s>", keyType, name)
+			fmt.Fprintf(&b, "        public %s Rows = new %s();\n\n", rowsType, rowsType)
+			writeCSharpSummary(&b, 2, "解析JSON文本")
+			fmt.Fprintf(&b, "        public static %sTable Parse(string json)\n        {\n", name)
+			fmt.Fprintf(&b, "            var table = new %sTable();\n", name)
+			b.WriteString("            if (json.Trim().Length > 0)\n            {\n")
+			fmt.Fprintf(&b, "                table.Rows = JsonConvert.DeserializeObject<%s>(json);\n", rowsType)
+			b.WriteString("            }\n            return table;\n        }\n\n")
+		} else {
+			fmt.Fprintf(&b, "        public List<%s> Rows = new List<%s>();\n\n", name, name)
+			writeCSharpSummary(&b, 2, "解析JSON文本，只有一条记录时导出的可能是对象")
+			fmt.Fprintf(&b, "        public static %sTable Parse(string json)\n        {\n", name)
+			fmt.Fprintf(&b, "            var table = new %sTable();\n", name)
+			b.WriteString("            json = json.Trim();\n")
+			b.WriteString("            if (json.StartsWith(\"{\"))\n            {\n")
+			fmt.Fprintf(&b, "                table.Rows.Add(JsonConvert.DeserializeObject<%s>(json));\n", name)
+			b.WriteString("            }\n            else if (json.Length > 0)\n            {\n")
+			fmt.Fprintf(&b, "                table.Rows = JsonConvert.DeserializeObject<List<%s>>(json);\n", name)
+			b.WriteString("            }\n            return table;\n        }\n\n")
+		}
+		writeCSharpSummary(&b, 2, "从客户端导出目录读取，文件不存在表示该表没有数据")
+		fmt.Fprintf(&b, "        public static %sTable Load(string dir)\n        {\n", name)
+		b.WriteString("            var path = Path.Combine(dir, FileName);\n")
+		fmt.Fprintf(&b, "            return File.Exists(path) ? Parse(File.ReadAllText(path)) : new %sTable();\n", name)
+		b.WriteString("        }\n    }\n}\n")
+		if err := writeFile(options.Output, name+".cs", b.Bytes()); err != nil {
+			return err
+		}
+		loaded = append(loaded, table)
+	}
+
+	var b bytes.Buffer
+	writeCSharpHeader(&b, namespace)
+	if usePair {
+		writeCSharpSummary(&b, 1, "二元组")
+		b.WriteString("    public class Pair\n    {\n")
+		b.WriteString("        [JsonProperty(\"x\")]\n        public int X;\n\n")
+		b.WriteString("        [JsonProperty(\"y\")]\n        public int Y;\n    }\n\n")
+	}
+	if useTriple {
+		writeCSharpSummary(&b, 1, "三元组")
+		b.WriteString("    public class Triple\n    {\n")
+		b.WriteString("        [JsonProperty(\"x\")]\n        public int X;\n\n")
+		b.WriteString("        [JsonProperty(\"y\")]\n        public int Y;\n\n")
+		b.WriteString("        [JsonProperty(\"z\")]\n        public int Z;\n    }\n\n")
+	}
+	writeCSharpSummary(&b, 1, "所有配置表")
+	b.WriteString("    public class Tables\n    {\n")
+	for _, table := range loaded {
+		name := util.CamelCase(table.Name)
+		fmt.Fprintf(&b, "        public %sTable %s;\n", name, name)
+	}
+	if len(loaded) > 0 {
+		b.WriteString("\n")
+	}
+	writeCSharpSummary(&b, 2, "从客户端导出目录读取所有配置表")
+	b.WriteString("        public static Tables Load(string dir)\n        {\n")
+	b.WriteString("            var tables = new Tables();\n")
+	for _, table := range loaded {
+		name := util.CamelCase(table.Name)
+		fmt.Fprintf(&b, "            tables.%s = %sTable.Load(dir);\n", name, name)
+	}
+	b.WriteString("            return tables;\n        }\n    }\n}\n")
+	return writeFile(options.Output, "Tables.cs", b.Bytes())
+}
+
+// csharpType 表格类型对应的C#类型，类型在读取表头时已经校验，出现未知类型时返回错误
+func csharpType(options *Options, form string) (string, error) {
+	if s := options.structType(form); s != nil {
+		return util.CamelCase(s.Name), nil
+	}
+	if key, value, ok := types.ParseMap(form); ok {
+		keyType, err := csharpType(options, key)
+		if err != nil {
+			return "", err
+		}
+		valueType, err := csharpType(options, value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Dictionary<%s, %s>", keyType, valueType), nil
+	}
+	if elem, ok := types.ParseArray(types.BaseType(form)); ok {
+		elemType, err := csharpType(options, elem)
+		if err != nil {
+			return "", err
+		}
+		return "List<" + elemType + ">", nil
+	}
+	switch types.BaseType(form) {
+	case "int":
+		return "int", nil
+	case "long":
+		return "long", nil
+	case "float":
+		return "float", nil
+	case "number":
+		return "double", nil
+	case "bool":
+		return "bool", nil
+	case "enum":
+		name, _ := types.ParseEnum(form)
+		return util.CamelCase(name), nil
+	case "array":
+		return "List<object>", nil
+	case "object":
+		return "Dictionary<string, object>", nil
+	case "map<string>":
+		return "Dictionary<string, string>", nil
+	case "pair":
+		return "Pair", nil
+	case "triple":
+		return "Triple", nil
+	case "string", "date":
+		return "string", nil
+	default:
+		return "", fmt.Errorf("unknown type %s", form)
+	}
+}
+
+// csharpEnums 生成枚举定义，枚举值名称和导出的名称一致
+func csharpEnums(options *Options, namespace string) []byte {
+	var b bytes.Buffer
+	writeCSharpHeader(&b, namespace)
+	for i, enum := range options.Enums {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeCSharpSummary(&b, 1, "枚举 "+enum.Name)
+		fmt.Fprintf(&b, "    public enum %s\n    {\n", util.CamelCase(enum.Name))
+		for j, v := range enum.Values {
+			if j > 0 {
+				b.WriteString("\n")
+			}
+			if v.Comment != "" {
+				writeCSharpSummary(&b, 2, v.Comment)
+			}
+			fmt.Fprintf(&b, "        %s = %d,\n", v.Name, v.Value)
+		}
+		b.WriteString("    }\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// writeCSharpField 生成字段声明，可以为null的值类型字段使用可空类型
+func writeCSharpField(b *bytes.Buffer, options *Options, name string, form string, nullable bool) error {
+	t, err := csharpType(options, form)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "        [JsonProperty(%q)]\n", name)
+	if options.EnumName && types.BaseType(form) == "enum" {
+		b.WriteString("        [JsonConverter(typeof(StringEnumConverter))]\n")
+	}
+	switch types.BaseType(form) {
+	case "int", "long", "float", "number", "bool", "enum":
+		if nullable {
+			t += "?"
+		}
+	}
+	fmt.Fprintf(b, "        public %s %s;\n", t, util.CamelCase(name))
+	return nil
+}
+
+// csharpStructs 生成自定义结构体对应的类
+func csharpStructs(options *Options, namespace string) ([]byte, error) {
+	var b bytes.Buffer
+	writeCSharpHeader(&b, namespace)
+	for i, s := range options.Structs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		comment := s.Comment
+		if comment == "" {
+			comment = "自定义类型 " + s.Name
+		}
+		writeCSharpSummary(&b, 1, comment)
+		fmt.Fprintf(&b, "    public class %s\n    {\n", util.CamelCase(s.Name))
+		for j, field := range s.Fields {
+			if j > 0 {
+				b.WriteString("\n")
+			}
+			if err := writeCSharpField(&b, options, field.Name, field.Type, field.Nullable); err != nil {
+				return nil, fmt.Errorf("type %s: field %s: %w", s.Name, field.Name, err)
+			}
+		}
+		b.WriteString("    }\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+// writeCSharpHeader 生成文件头
+func writeCSharpHeader(b *bytes.Buffer, namespace string) {
+	b.WriteString("// <auto-generated>\n// Code generated by excel-tools. DO NOT EDIT.\n// </auto-generated>\n\n")
+	b.WriteString("using System.Collections.Generic;\nusing System.IO;\nusing Newtonsoft.Json;\nusing Newtonsoft.Json.Converters;\n\n")
+	fmt.Fprintf(b, "namespace %s\n{\n", namespace)
+}
+
+// writeCSharpSummary 生成XML文档注释
+func writeCSharpSummary(b *bytes.Buffer, depth int, text string) {
+	indent := strings.Repeat("    ", depth)
+	fmt.Fprintf(b, "%s/// <summary>\n", indent)
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(b, "%s/// %s\n", indent, xmlEscape(strings.TrimSpace(line)))
+	}
+	fmt.Fprintf(b, "%s/// </summary>\n", indent)
+}
+
+// xmlEscape 转义XML文档注释中的特殊字符
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,78 @@
+package codegen
+
+import (
+	"excel-tools/export"
+	"testing"
+)
+
+func TestCSharpGenerator(t *testing.T) {
+	options := testOptions(t)
+	if err := new(CSharpGenerator).Generate(options, testTables()); err != nil {
+		t.Fatal(err)
+	}
+	expectContains(t, "Hero.cs", readGenerated(t, options.Output, "Hero.cs"),
+		"namespace Config\n{",
+		"        /// <summary>\n        /// 编号\n        /// 第二行\n        /// </summary>\n        [JsonProperty(\"id\")]\n        public int Id;",
+		"public Quality Quality;",
+		"public int? Level;",
+		"public HeroBonus Bonus;",
+		"public List<Reward> Rewards;",
+		"public Dictionary<int, float> Attrs;",
+		"public List<object> Values;",
+		"public int Ref;",
+		"public List<Hero> Rows = new List<Hero>();",
+	)
+	expectContains(t, "Shop.cs", readGenerated(t, options.Output, "Shop.cs"),
+		"public Dictionary<int, Shop> Rows = new Dictionary<int, Shop>();",
+		"table.Rows = JsonConvert.DeserializeObject<Dictionary<int, Shop>>(json);",
+	)
+	expectContains(t, "Structs.cs", readGenerated(t, options.Output, "Structs.cs"),
+		"    /// <summary>\n    /// 奖励\n    /// 第二行\n    /// </summary>\n    public class Reward",
+		"public Pair P;",
+		"public float? Rate;",
+	)
+	expectContains(t, "Enums.cs", readGenerated(t, options.Output, "Enums.cs"),
+		"public enum Quality",
+		"Common = 1,",
+	)
+	// 只有自定义结构体用到pair时也需要生成Pair
+	expectContains(t, "Tables.cs", readGenerated(t, options.Output, "Tables.cs"),
+		"public class Pair",
+		"public HeroTable Hero;",
+		"tables.Shop = ShopTable.Load(dir);",
+	)
+}
+
+func TestCSharpGeneratorEnumName(t *testing.T) {
+	options := testOptions(t)
+	options.EnumName = true
+	options.Package = "Game.Config"
+	if err := new(CSharpGenerator).Generate(options, testTables()); err != nil {
+		t.Fatal(err)
+	}
+	expectContains(t, "Hero.cs", readGenerated(t, options.Output, "Hero.cs"),
+		"namespace Game.Config",
+		"[JsonProperty(\"quality\")]\n        [JsonConverter(typeof(StringEnumConverter))]\n        public Quality Quality;",
+	)
+}
+
+func TestCSharpGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []*Table
+		want   string
+	}{
+		{"class name", []*Table{{Name: "item", Client: []export.Field{{Name: "item", Type: "int"}}}},
+			`table item: field "item" has the same name as the class`},
+		{"common file", []*Table{{Name: "tables", Client: []export.Field{{Name: "id", Type: "int"}}}},
+			"table tables: generated file Tables.cs conflicts with the common file"},
+		{"unknown type", []*Table{{Name: "item", Client: []export.Field{{Name: "kind", Type: "map<int,nope>"}}}},
+			"table item: field kind: unknown type nope"},
+	}
+	for _, tt := range tests {
+		err := new(CSharpGenerator).Generate(testOptions(t), tt.tables)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: Generate error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
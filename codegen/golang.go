@@ -0,0 +1,309 @@
+package codegen
+
+import (
+	"bytes"
+	"excel-tools/types"
+	"excel-tools/util"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+type GoGenerator struct{}
+
+// Generate 生成Go结构体以及加载代码，只包含服务端字段
+//
+// 每个表生成一个 <table>.go 文件，包含结构体和 Load<Table> 函数，另外生成 loader.go 提供 Load 一次读取服务端导出目录下的所有表。
+// 以主键作为键导出的表读取为 map[主键]*<Table>，否则读取为 []*<Table>。存在枚举定义时生成 enums.go 包含枚举类型和常量，
+// 存在自定义结构体时生成 structs.go。
+func (*GoGenerator) Generate(options *Options, tables []*Table) error {
+	pkg := options.Package
+	if pkg == "" {
+		pkg = "config"
+	}
+	if err := checkTypes(options, tables); err != nil {
+		return err
+	}
+	goFile := func(table *Table) string { return table.Name + ".go" }
+	if err := checkFiles(tables, goFile, "enums.go", "structs.go", "loader.go"); err != nil {
+		return err
+	}
+	if len(options.Enums) > 0 {
+		if err := writeGoFile(options.Output, "enums.go", goEnums(options, pkg)); err != nil {
+			return err
+		}
+	}
+	if len(options.Structs) > 0 {
+		data, err := goStructs(options, pkg)
+		if err != nil {
+			return err
+		}
+		if err := writeGoFile(options.Output, "structs.go", data); err != nil {
+			return err
+		}
+	}
+	var (
+		// 自定义结构体中用到的pair/triple也需要生成对应的类型
+		usePair   = options.structsUse("pair")
+		useTriple = options.structsUse("triple")
+		loaded    []*Table
+		// 每个表读取后的类型
+		rowsTypes = make(map[string]string)
+	)
+	for _, table := range tables {
+		if len(table.Server) == 0 {
+			continue
+		}
+		name := util.CamelCase(table.Name)
+		if err := checkIdentifier("table", table.Name, name); err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+		writeGoHeader(&b, pkg)
+		fmt.Fprintf(&b, "// %s 对应 %s 表的一行数据\n", name, table.Name)
+		fmt.Fprintf(&b, "type %s struct {\n", name)
+		for _, field := range table.Server {
+			fieldName := util.CamelCase(field.Name)
+			if err := checkIdentifier("field", field.Name, fieldName); err != nil {
+				return fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			if field.Note != "" {
+				writeGoComment(&b, "\t", fieldName+" "+field.Note)
+			}
+			t, err := goFieldType(options, field.Type, field.Nullable)
+			if err != nil {
+				return fmt.Errorf("table %s: field %s: %w", table.Name, field.Name, err)
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s\"`\n", fieldName, t, field.Name)
+			types.Walk(field.Type, func(t string) {
+				usePair = usePair || t == "pair"
+				useTriple = useTriple || t == "triple"
+			})
+		}
+		b.WriteString("}\n\n")
+		rowsType, loadFunc := "[]*"+name, "load"
+		if key, ok := table.keyField(table.Server); ok {
+			keyType, err := goType(options, key.Type)
+			if err != nil {
+				return fmt.Errorf("table %s: field %s: %w", table.Name, key.Name, err)
+			}
+			rowsType, loadFunc = fmt.Sprintf("map[%s]*%s", keyType, name), "loadKeyed"
+		}
+		rowsTypes[table.Name] = rowsType
+		fmt.Fprintf(&b, "// Load%s 读取 %s.json\n", name, table.Name)
+		fmt.Fprintf(&b, "func Load%s(dir string) (%s, error) {\n", name, rowsType)
+		fmt.Fprintf(&b, "\tvar rows %s\n", rowsType)
+		fmt.Fprintf(&b, "\tif err := %s(dir, %q, &rows); err != nil {\n", loadFunc, table.Name+".json")
+		b.WriteString("\t\treturn nil, err\n\t}\n\treturn rows, nil\n}\n")
+		if err := writeGoFile(options.Output, table.Name+".go", b.Bytes()); err != nil {
+			return err
+		}
+		loaded = append(loaded, table)
+	}
+
+	var b bytes.Buffer
+	writeGoHeader(&b, pkg)
+	b.WriteString("import (\n\t\"bytes\"\n\t\"encoding/json\"\n\t\"fmt\"\n\t\"io/ioutil\"\n\t\"os\"\n\t\"path/filepath\"\n)\n\n")
+	if usePair {
+		b.WriteString("// Pair 二元组\ntype Pair struct {\n\tX int `json:\"x\"`\n\tY int `json:\"y\"`\n}\n\n")
+	}
+	if useTriple {
+		b.WriteString("// Triple 三元组\ntype Triple struct {\n\tX int `json:\"x\"`\n\tY int `json:\"y\"`\n\tZ int `json:\"z\"`\n}\n\n")
+	}
+	b.WriteString("// Tables 所有配置表\ntype Tables struct {\n")
+	for _, table := range loaded {
+		name := util.CamelCase(table.Name)
+		fmt.Fprintf(&b, "\t%s %s\n", name, rowsTypes[table.Name])
+	}
+	b.WriteString("}\n\n")
+	b.WriteString("// Load 从服务端导出目录读取所有配置表\nfunc Load(dir string) (*Tables, error) {\n")
+	b.WriteString("\tvar (\n\t\ttables = new(Tables)\n\t\terr    error\n\t)\n")
+	for _, table := range loaded {
+		name := util.CamelCase(table.Name)
+		fmt.Fprintf(&b, "\tif tables.%s, err = Load%s(dir); err != nil {\n\t\treturn nil, err\n\t}\n", name, name)
+	}
+	b.WriteString("\treturn tables, nil\n}\n\n")
+	b.WriteString(goLoadFunc)
+	return writeGoFile(options.Output, "loader.go", b.Bytes())
+}
+
+// goLoadFunc 读取单个JSON文件的公共函数
+const goLoadFunc = `// load 读取单个配置文件，文件不存在表示该表没有数据，只有一条记录时导出的可能是对象
+func load(dir string, file string, rows interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		data = append(append([]byte{'['}, data...), ']')
+	}
+	if err := json.Unmarshal(data, rows); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// loadKeyed 读取以主键作为键导出的配置文件，文件不存在表示该表没有数据
+func loadKeyed(dir string, file string, rows interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, rows); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+`
+
+// goFieldType 字段的Go类型，可以为null的字段使用指针区分null和零值
+func goFieldType(options *Options, form string, nullable bool) (string, error) {
+	t, err := goType(options, form)
+	if err != nil {
+		return "", err
+	}
+	if nullable && !strings.HasPrefix(t, "*") && !strings.HasPrefix(t, "[]") && !strings.HasPrefix(t, "map[") {
+		return "*" + t, nil
+	}
+	return t, nil
+}
+
+// goType 表格类型对应的Go类型，类型在读取表头时已经校验，出现未知类型时返回错误
+func goType(options *Options, form string) (string, error) {
+	if s := options.structType(form); s != nil {
+		return "*" + util.CamelCase(s.Name), nil
+	}
+	if key, value, ok := types.ParseMap(form); ok {
+		keyType, err := goType(options, key)
+		if err != nil {
+			return "", err
+		}
+		valueType, err := goType(options, value)
+		if err != nil {
+			return "", err
+		}
+		return "map[" + keyType + "]" + valueType, nil
+	}
+	if elem, ok := types.ParseArray(types.BaseType(form)); ok {
+		elemType, err := goType(options, elem)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elemType, nil
+	}
+	switch types.BaseType(form) {
+	case "int":
+		return "int", nil
+	case "long":
+		return "int64", nil
+	case "float":
+		return "float32", nil
+	case "number":
+		return "float64", nil
+	case "bool":
+		return "bool", nil
+	case "enum":
+		name, _ := types.ParseEnum(form)
+		return util.CamelCase(name), nil
+	case "array":
+		return "[]interface{}", nil
+	case "object":
+		return "map[string]interface{}", nil
+	case "map<string>":
+		return "map[string]string", nil
+	case "pair":
+		return "*Pair", nil
+	case "triple":
+		return "*Triple", nil
+	case "string", "date":
+		return "string", nil
+	default:
+		return "", fmt.Errorf("unknown type %s", form)
+	}
+}
+
+// goEnums 生成枚举类型以及常量，常量名为 枚举名+枚举值名，例如 QualityEpic
+func goEnums(options *Options, pkg string) []byte {
+	var b bytes.Buffer
+	writeGoHeader(&b, pkg)
+	for _, enum := range options.Enums {
+		name := util.CamelCase(enum.Name)
+		underlying := "int"
+		if options.EnumName {
+			underlying = "string"
+		}
+		fmt.Fprintf(&b, "// %s 枚举 %s\ntype %s %s\n\n", name, enum.Name, name, underlying)
+		b.WriteString("const (\n")
+		for _, v := range enum.Values {
+			constName := name + util.CamelCase(v.Name)
+			if v.Comment != "" {
+				writeGoComment(&b, "\t", constName+" "+v.Comment)
+			}
+			if options.EnumName {
+				fmt.Fprintf(&b, "\t%s %s = %q\n", constName, name, v.Name)
+			} else {
+				fmt.Fprintf(&b, "\t%s %s = %d\n", constName, name, v.Value)
+			}
+		}
+		b.WriteString(")\n\n")
+	}
+	return b.Bytes()
+}
+
+// goStructs 生成自定义结构体
+func goStructs(options *Options, pkg string) ([]byte, error) {
+	var b bytes.Buffer
+	writeGoHeader(&b, pkg)
+	for _, s := range options.Structs {
+		name := util.CamelCase(s.Name)
+		comment := s.Comment
+		if comment == "" {
+			comment = "自定义类型 " + s.Name
+		}
+		writeGoComment(&b, "", name+" "+comment)
+		fmt.Fprintf(&b, "type %s struct {\n", name)
+		for _, field := range s.Fields {
+			t, err := goFieldType(options, field.Type, field.Nullable)
+			if err != nil {
+				return nil, fmt.Errorf("type %s: field %s: %w", s.Name, field.Name, err)
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s\"`\n", util.CamelCase(field.Name), t, field.Name)
+		}
+		b.WriteString("}\n\n")
+	}
+	return b.Bytes(), nil
+}
+
+// writeGoComment 生成注释，表头的注释可能有多行，每一行都以//开头，避免之后的行成为代码
+func writeGoComment(b *bytes.Buffer, indent string, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			fmt.Fprintf(b, "%s//\n", indent)
+		} else {
+			fmt.Fprintf(b, "%s// %s\n", indent, line)
+		}
+	}
+}
+
+// writeGoHeader 生成文件头
+func writeGoHeader(b *bytes.Buffer, pkg string) {
+	b.WriteString("// Code generated by excel-tools. DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+}
+
+// writeGoFile 格式化后写出Go代码
+func writeGoFile(dir string, name string, data []byte) error {
+	src, err := format.Source(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w\n%s", name, err, strings.TrimSpace(string(data)))
+	}
+	return writeFile(dir, name, src)
+}
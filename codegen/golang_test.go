@@ -0,0 +1,117 @@
+package codegen
+
+import (
+	"excel-tools/export"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// checkGoPackage 解析并类型检查生成的Go代码
+func checkGoPackage(t *testing.T, dir string) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("parse %s: %v\n%s", filepath.Base(path), err, src)
+		}
+		files = append(files, file)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("config", fset, files, nil); err != nil {
+		t.Fatalf("type check: %v", err)
+	}
+}
+
+func TestGoGenerator(t *testing.T) {
+	options := testOptions(t)
+	if err := new(GoGenerator).Generate(options, testTables()); err != nil {
+		t.Fatal(err)
+	}
+	checkGoPackage(t, options.Output)
+
+	expectContains(t, "hero.go", readGenerated(t, options.Output, "hero.go"),
+		"\t// Id 编号\n\t// 第二行\n\tId int `json:\"id\"`",
+		"Quality Quality `json:\"quality\"`",
+		"Level *int `json:\"level\"`",
+		"Bonus *HeroBonus `json:\"bonus\"`",
+		"Rewards []*Reward `json:\"rewards\"`",
+		"Attrs map[int]float32 `json:\"attrs\"`",
+		"func LoadHero(dir string) ([]*Hero, error) {",
+	)
+	expectContains(t, "enums.go", readGenerated(t, options.Output, "enums.go"),
+		"\t// QualityCommon 普通\n\t// 第二行\n\tQualityCommon Quality = 1",
+	)
+	expectContains(t, "structs.go", readGenerated(t, options.Output, "structs.go"),
+		"// Reward 奖励\n// 第二行\ntype Reward struct {",
+		"Rate *float32 `json:\"rate\"`",
+	)
+	expectContains(t, "loader.go", readGenerated(t, options.Output, "loader.go"),
+		"type Pair struct {",
+		"Shop map[int]*Shop",
+		"if tables.Hero, err = LoadHero(dir); err != nil {",
+	)
+}
+
+func TestGoGeneratorEnumName(t *testing.T) {
+	options := testOptions(t)
+	options.EnumName = true
+	if err := new(GoGenerator).Generate(options, testTables()); err != nil {
+		t.Fatal(err)
+	}
+	checkGoPackage(t, options.Output)
+	expectContains(t, "enums.go", readGenerated(t, options.Output, "enums.go"),
+		"type Quality string",
+		"QualityEpic Quality = \"Epic\"",
+	)
+}
+
+func TestGoGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []*Table
+	}{
+		{"table name", []*Table{{Name: "道具", Server: []export.Field{{Name: "id", Type: "int"}}}}},
+		{"field name", []*Table{{Name: "item", Server: []export.Field{{Name: "1a", Type: "int"}}}}},
+		{"same type", []*Table{
+			{Name: "hero_item", Server: []export.Field{{Name: "id", Type: "int"}}},
+			{Name: "HeroItem", Server: []export.Field{{Name: "id", Type: "int"}}},
+		}},
+		{"common file", []*Table{{Name: "loader", Server: []export.Field{{Name: "id", Type: "int"}}}}},
+		{"type name", []*Table{{Name: "reward", Server: []export.Field{{Name: "id", Type: "int"}}}}},
+	}
+	for _, tt := range tests {
+		if err := new(GoGenerator).Generate(testOptions(t), tt.tables); err == nil {
+			t.Errorf("%s: Generate succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestGoGeneratorUnknownType(t *testing.T) {
+	tables := []*Table{{Name: "item", Server: []export.Field{{Name: "kind", Type: "nope[]"}}}}
+	err := new(GoGenerator).Generate(testOptions(t), tables)
+	if err == nil || err.Error() != "table item: field kind: unknown type nope" {
+		t.Errorf("Generate error = %v, want the table and field of the unknown type", err)
+	}
+	options := testOptions(t)
+	options.Structs[0].Fields[0].Type = "itn"
+	err = new(GoGenerator).Generate(options, testTables())
+	if err == nil || err.Error() != "type Reward: field item: unknown type itn" {
+		t.Errorf("Generate error = %v, want the type and field of the unknown type", err)
+	}
+}
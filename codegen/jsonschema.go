@@ -0,0 +1,328 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"excel-tools/export"
+	"excel-tools/types"
+	"fmt"
+	"path/filepath"
+)
+
+// schemaDraft JSON Schema版本
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// datePattern 导出的日期格式，例如 2021-12-12 00:00:00
+const datePattern = `^\d{4}-\d{1,2}-\d{1,2} \d{1,2}:\d{1,2}:\d{1,2}$`
+
+type JsonSchemaGenerator struct{}
+
+// Generate 为每个表的客户端和服务端数据分别生成JSON Schema(draft 2020-12)
+//
+// 输出到 <output>/client/<table>.schema.json 和 <output>/server/<table>.schema.json，
+// 可以在CI中使用通用的校验工具校验导出的配置文件。存在枚举定义时另外生成两端共用的 enums.schema.json。
+func (*JsonSchemaGenerator) Generate(options *Options, tables []*Table) error {
+	schemaFile := func(table *Table) string { return table.Name + ".schema.json" }
+	if err := checkFiles(tables, schemaFile, "enums.schema.json"); err != nil {
+		return err
+	}
+	if len(options.Enums) > 0 {
+		data, err := json.MarshalIndent(enumsSchema(options), "", "\t")
+		if err != nil {
+			return err
+		}
+		for _, dir := range []string{"client", "server"} {
+			if err := writeFile(filepath.Join(options.Output, dir), "enums.schema.json", data); err != nil {
+				return err
+			}
+		}
+	}
+	for _, table := range tables {
+		sides := []struct {
+			dir    string
+			fields []export.Field
+		}{
+			{"client", table.Client},
+			{"server", table.Server},
+		}
+		for _, side := range sides {
+			if len(side.fields) == 0 {
+				continue
+			}
+			schema, err := tableSchema(options, table, side.fields)
+			if err != nil {
+				return err
+			}
+			data, err := json.MarshalIndent(schema, "", "\t")
+			if err != nil {
+				return err
+			}
+			if err := writeFile(filepath.Join(options.Output, side.dir), table.Name+".schema.json", data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tableSchema 表数据的Schema，只有一条记录时导出的可能是对象，所以同时允许数组和对象；以主键作为键导出时为对象
+func tableSchema(options *Options, table *Table, fields []export.Field) (object, error) {
+	properties := object{}
+	var required []string
+	for _, field := range fields {
+		property, err := fieldSchema(options, field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: field %s: %w", table.Name, field.Name, err)
+		}
+		property = constraintSchema(property, field.Constraint)
+		if field.Nullable {
+			property = nullableSchema(property)
+		}
+		if field.Note != "" {
+			property = append(object{{"description", field.Note}}, property...)
+		}
+		if field.Default != nil {
+			property = append(property, member{"default", field.Default})
+		}
+		properties = append(properties, member{field.Name, property})
+		if alwaysPresent(field) {
+			required = append(required, field.Name)
+		}
+	}
+	row := object{
+		{"type", "object"},
+		{"properties", properties},
+	}
+	if len(required) > 0 {
+		row = append(row, member{"required", required})
+	}
+	row = append(row, member{"additionalProperties", false})
+
+	schema := object{
+		{"$schema", schemaDraft},
+		{"$id", table.Name + ".schema.json"},
+		{"title", table.Name},
+		{"$defs", object{{"row", row}}},
+	}
+	if table.Keyed {
+		return append(schema,
+			member{"type", "object"},
+			member{"additionalProperties", object{{"$ref", "#/$defs/row"}}},
+		), nil
+	}
+	return append(schema, member{"anyOf", []interface{}{
+		object{{"type", "array"}, {"items", object{{"$ref", "#/$defs/row"}}}},
+		object{{"$ref", "#/$defs/row"}},
+	}}), nil
+}
+
+// constraintSchema 取值约束对应的Schema，数组的约束作用于元素
+func constraintSchema(schema object, constraint types.Constraint) object {
+	if constraint == nil {
+		return schema
+	}
+	for i, m := range schema {
+		if items, ok := m.value.(object); ok && m.key == "items" {
+			schema[i].value = constraintSchema(items, constraint)
+			return schema
+		}
+	}
+	var keywords object
+	switch c := constraint.(type) {
+	case *types.RangeConstraint:
+		if c.Min != nil {
+			keywords = append(keywords, member{"minimum", *c.Min})
+		}
+		if c.Max != nil {
+			keywords = append(keywords, member{"maximum", *c.Max})
+		}
+	case *types.PatternConstraint:
+		keywords = append(keywords, member{"pattern", c.Pattern.String()})
+	case *types.SetConstraint:
+		keywords = append(keywords, member{"enum", c.Values})
+	}
+	for _, keyword := range keywords {
+		for _, m := range schema {
+			// 已经存在同名关键字时(例如date的pattern、enum的取值)同时满足两者
+			if m.key == keyword.key {
+				return object{{"allOf", []interface{}{schema, keywords}}}
+			}
+		}
+	}
+	return append(schema, keywords...)
+}
+
+// nullableSchema 允许为null的Schema
+func nullableSchema(schema object) object {
+	return object{{"anyOf", []interface{}{schema, object{{"type", "null"}}}}}
+}
+
+// fieldSchema 表格类型对应的Schema，类型在读取表头时已经校验，出现未知类型时返回错误
+func fieldSchema(options *Options, form string) (object, error) {
+	if s := options.structType(form); s != nil {
+		properties := object{}
+		var keys []string
+		for _, field := range s.Fields {
+			property, err := fieldSchema(options, field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("type %s: field %s: %w", s.Name, field.Name, err)
+			}
+			if field.Nullable {
+				property = nullableSchema(property)
+			}
+			properties = append(properties, member{field.Name, property})
+			keys = append(keys, field.Name)
+		}
+		schema := object{{"type", "object"}, {"properties", properties}}
+		// 点号分隔的字段名生成的结构体，空单元格对应的字段会被省略
+		if !s.Nested {
+			schema = append(schema, member{"required", keys})
+		}
+		return append(schema, member{"additionalProperties", false}), nil
+	}
+	if key, value, ok := types.ParseMap(form); ok {
+		schema := object{{"type", "object"}}
+		if key != "string" {
+			schema = append(schema, member{"propertyNames", object{{"pattern", `^-?\d+$`}}})
+		}
+		values, err := fieldSchema(options, value)
+		if err != nil {
+			return nil, err
+		}
+		return append(schema, member{"additionalProperties", values}), nil
+	}
+	if elem, ok := types.ParseArray(types.BaseType(form)); ok {
+		items, err := fieldSchema(options, elem)
+		if err != nil {
+			return nil, err
+		}
+		return arraySchema(items), nil
+	}
+	switch types.BaseType(form) {
+	case "int", "long":
+		return object{{"type", "integer"}}, nil
+	case "float", "number":
+		return object{{"type", "number"}}, nil
+	case "bool":
+		return object{{"type", "boolean"}}, nil
+	case "enum":
+		return enumSchema(options, form), nil
+	case "date":
+		return object{{"type", "string"}, {"pattern", datePattern}}, nil
+	case "array":
+		// 以JSON数组填写时元素可以是任意JSON值，不限制元素类型
+		return object{{"type", "array"}}, nil
+	case "object":
+		return object{{"type", "object"}}, nil
+	case "map<string>":
+		return object{{"type", "object"}, {"additionalProperties", object{{"type", "string"}}}}, nil
+	case "pair":
+		return tupleSchema("x", "y"), nil
+	case "triple":
+		return tupleSchema("x", "y", "z"), nil
+	case "string":
+		return object{{"type", "string"}}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %s", form)
+	}
+}
+
+// enumSchema 枚举只允许已定义的数值或者名称
+func enumSchema(options *Options, form string) object {
+	name, _ := types.ParseEnum(form)
+	enum := options.enum(name)
+	if enum == nil {
+		return object{}
+	}
+	values := make([]interface{}, 0, len(enum.Values))
+	for _, v := range enum.Values {
+		if options.EnumName {
+			values = append(values, v.Name)
+		} else {
+			values = append(values, v.Value)
+		}
+	}
+	return object{{"enum", values}}
+}
+
+// enumsSchema 枚举定义导出文件的Schema，每个枚举为 名称->数值 的对象
+func enumsSchema(options *Options) object {
+	properties := object{}
+	var names []string
+	for _, enum := range options.Enums {
+		members := object{}
+		var keys []string
+		for _, v := range enum.Values {
+			members = append(members, member{v.Name, object{{"const", v.Value}}})
+			keys = append(keys, v.Name)
+		}
+		properties = append(properties, member{enum.Name, object{
+			{"type", "object"},
+			{"properties", members},
+			{"required", keys},
+			{"additionalProperties", false},
+		}})
+		names = append(names, enum.Name)
+	}
+	return object{
+		{"$schema", schemaDraft},
+		{"$id", "enums.schema.json"},
+		{"title", "enums"},
+		{"type", "object"},
+		{"properties", properties},
+		{"required", names},
+		{"additionalProperties", false},
+	}
+}
+
+// arraySchema 数组
+func arraySchema(items object) object {
+	return object{{"type", "array"}, {"items", items}}
+}
+
+// tupleSchema pair/triple对象，每个键都是整数
+func tupleSchema(keys ...string) object {
+	properties := object{}
+	for _, key := range keys {
+		properties = append(properties, member{key, object{{"type", "integer"}}})
+	}
+	return object{
+		{"type", "object"},
+		{"properties", properties},
+		{"required", keys},
+		{"additionalProperties", false},
+	}
+}
+
+// member JSON对象的一个键值对
+type member struct {
+	key   string
+	value interface{}
+}
+
+// object 保持键顺序的JSON对象，保证生成的Schema和表格列顺序一致
+type object []member
+
+// MarshalJSON 按顺序输出键值对
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package codegen
+
+import (
+	"encoding/json"
+	"excel-tools/export"
+	"excel-tools/types"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readSchema 读取生成的Schema
+func readSchema(t *testing.T, dir string, side string, name string) map[string]interface{} {
+	t.Helper()
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(readGenerated(t, filepath.Join(dir, side), name)), &schema); err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// expectSchema 比较Schema中的一个值，want为JSON文本
+func expectSchema(t *testing.T, got interface{}, want string) {
+	t.Helper()
+	var expected interface{}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		data, _ := json.Marshal(got)
+		t.Errorf("schema = %s\nwant %s", data, want)
+	}
+}
+
+func TestJsonSchemaGenerator(t *testing.T) {
+	options := testOptions(t)
+	tables := testTables()
+	tables[0].Client = append(tables[0].Client,
+		export.Field{Name: "star", Type: "int[]", Constraint: &types.SetConstraint{Values: []interface{}{1, 2}}},
+		export.Field{Name: "weight", Type: "int", Default: 10},
+	)
+	if err := new(JsonSchemaGenerator).Generate(options, tables); err != nil {
+		t.Fatal(err)
+	}
+	hero := readSchema(t, options.Output, "client", "hero.schema.json")
+	expectSchema(t, hero["anyOf"], `[{"type": "array", "items": {"$ref": "#/$defs/row"}}, {"$ref": "#/$defs/row"}]`)
+	row := hero["$defs"].(map[string]interface{})["row"].(map[string]interface{})
+	expectSchema(t, row["required"], `["name", "level", "values", "weight"]`)
+	properties := row["properties"].(map[string]interface{})
+	expectSchema(t, properties["id"], `{"description": "编号\n第二行", "type": "integer"}`)
+	expectSchema(t, properties["quality"], `{"enum": [1, 4]}`)
+	expectSchema(t, properties["level"], `{"anyOf": [{"type": "integer"}, {"type": "null"}]}`)
+	expectSchema(t, properties["bonus"], `{"type": "object", "properties": {
+		"p": {"type": "object", "properties": {"x": {"type": "integer"}, "y": {"type": "integer"}}, "required": ["x", "y"], "additionalProperties": false},
+		"rate": {"anyOf": [{"type": "number"}, {"type": "null"}]}
+	}, "additionalProperties": false}`)
+	expectSchema(t, properties["rewards"], `{"type": "array", "items": {"type": "object",
+		"properties": {"item": {"type": "integer"}, "count": {"type": "integer"}}, "required": ["item", "count"], "additionalProperties": false}}`)
+	expectSchema(t, properties["attrs"], `{"type": "object", "propertyNames": {"pattern": "^-?\\d+$"}, "additionalProperties": {"type": "number"}}`)
+	expectSchema(t, properties["values"], `{"type": "array"}`)
+	expectSchema(t, properties["star"], `{"type": "array", "items": {"type": "integer", "enum": [1, 2]}}`)
+	expectSchema(t, properties["weight"], `{"type": "integer", "default": 10}`)
+
+	shop := readSchema(t, options.Output, "server", "shop.schema.json")
+	expectSchema(t, shop["type"], `"object"`)
+	expectSchema(t, shop["additionalProperties"], `{"$ref": "#/$defs/row"}`)
+
+	enums := readSchema(t, options.Output, "server", "enums.schema.json")
+	expectSchema(t, enums["properties"], `{"Quality": {"type": "object",
+		"properties": {"Common": {"const": 1}, "Epic": {"const": 4}}, "required": ["Common", "Epic"], "additionalProperties": false}}`)
+}
+
+func TestJsonSchemaGeneratorUnknownType(t *testing.T) {
+	tables := []*Table{{Name: "item", Server: []export.Field{{Name: "kind", Type: "nope[]"}}}}
+	err := new(JsonSchemaGenerator).Generate(testOptions(t), tables)
+	if err == nil || err.Error() != "table item: field kind: unknown type nope" {
+		t.Errorf("Generate error = %v, want the table and field of the unknown type", err)
+	}
+	options := testOptions(t)
+	options.Structs[0].Fields[0].Type = "itn"
+	err = new(JsonSchemaGenerator).Generate(options, testTables())
+	if err == nil || err.Error() != "table hero: field rewards: type Reward: field item: unknown type itn" {
+		t.Errorf("Generate error = %v, want the type and field of the unknown type", err)
+	}
+}
@@ -0,0 +1,267 @@
+package codegen
+
+import (
+	"bytes"
+	"excel-tools/export"
+	"excel-tools/types"
+	"excel-tools/util"
+	"fmt"
+	"strings"
+)
+
+type TypeScriptGenerator struct{}
+
+// Generate 生成客户端JSON对应的TypeScript声明，只包含客户端字段
+//
+// 每个表生成一个 <table>.d.ts 文件，包含行数据接口和 <Table>Table 类型，另外生成 index.d.ts 统一导出。
+// 存在枚举定义时生成 enums.d.ts，枚举为 const enum，存在自定义结构体时生成 structs.d.ts，表中使用的类型从中导入。
+func (*TypeScriptGenerator) Generate(options *Options, tables []*Table) error {
+	if err := checkTypes(options, tables); err != nil {
+		return err
+	}
+	tsFile := func(table *Table) string { return table.Name + ".d.ts" }
+	if err := checkFiles(tables, tsFile, "enums.d.ts", "structs.d.ts", "index.d.ts"); err != nil {
+		return err
+	}
+	var index bytes.Buffer
+	index.WriteString("// Code generated by excel-tools. DO NOT EDIT.\n\n")
+	if len(options.Enums) > 0 {
+		if err := writeFile(options.Output, "enums.d.ts", tsEnums(options)); err != nil {
+			return err
+		}
+		fmt.Fprintf(&index, "export * from %q;\n", "./enums")
+	}
+	if len(options.Structs) > 0 {
+		data, err := tsStructs(options)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(options.Output, "structs.d.ts", data); err != nil {
+			return err
+		}
+		fmt.Fprintf(&index, "export * from %q;\n", "./structs")
+	}
+	for _, table := range tables {
+		if len(table.Client) == 0 {
+			continue
+		}
+		name := util.CamelCase(table.Name)
+		if err := checkIdentifier("table", table.Name, name); err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+		b.WriteString("// Code generated by excel-tools. DO NOT EDIT.\n\n")
+		var forms []string
+		for _, field := range table.Client {
+			forms = append(forms, field.Type)
+		}
+		writeTsImports(&b, options, forms)
+		fmt.Fprintf(&b, "/** %s 表的一行数据 */\n", table.Name)
+		fmt.Fprintf(&b, "export interface %s {\n", name)
+		for _, field := range table.Client {
+			if field.Note != "" {
+				fmt.Fprintf(&b, "\t/** %s */\n", tsComment(field.Note))
+			}
+			optional := "?"
+			if alwaysPresent(field) {
+				optional = ""
+			}
+			t, err := tsFieldType(options, field.Type, field.Nullable)
+			if err != nil {
+				return fmt.Errorf("table %s: field %s: %w", table.Name, field.Name, err)
+			}
+			fmt.Fprintf(&b, "\t%s%s: %s;\n", tsName(field.Name), optional, t)
+		}
+		b.WriteString("}\n\n")
+		if table.Keyed {
+			fmt.Fprintf(&b, "/** %s.json 的内容，以主键 %s 作为键 */\n", table.Name, table.Key)
+			fmt.Fprintf(&b, "export type %sTable = Record<string, %s>;\n", name, name)
+		} else {
+			fmt.Fprintf(&b, "/** %s.json 的内容，只有一条记录时导出的可能是对象 */\n", table.Name)
+			fmt.Fprintf(&b, "export type %sTable = %s[] | %s;\n", name, name, name)
+		}
+		if err := writeFile(options.Output, table.Name+".d.ts", b.Bytes()); err != nil {
+			return err
+		}
+		fmt.Fprintf(&index, "export * from %q;\n", "./"+table.Name)
+	}
+	return writeFile(options.Output, "index.d.ts", index.Bytes())
+}
+
+// alwaysPresent 空单元格是否仍会输出该字段，设置了默认值、可以为null、不能为空以及string/array/object会输出，其它类型会省略
+func alwaysPresent(field export.Field) bool {
+	if field.Default != nil || field.Nullable || field.Required {
+		return true
+	}
+	return field.Type == "string" || field.Type == "array" || field.Type == "object"
+}
+
+// tsFieldType 字段的TypeScript类型，可以为null的字段加上null
+func tsFieldType(options *Options, form string, nullable bool) (string, error) {
+	t, err := tsType(options, form)
+	if err != nil {
+		return "", err
+	}
+	if nullable {
+		return t + " | null", nil
+	}
+	return t, nil
+}
+
+// tsType 表格类型对应的TypeScript类型，JSON对象的键都是字符串，类型在读取表头时已经校验，出现未知类型时返回错误
+func tsType(options *Options, form string) (string, error) {
+	if s := options.structType(form); s != nil {
+		return util.CamelCase(s.Name), nil
+	}
+	if _, value, ok := types.ParseMap(form); ok {
+		t, err := tsType(options, value)
+		if err != nil {
+			return "", err
+		}
+		return "Record<string, " + t + ">", nil
+	}
+	if elem, ok := types.ParseArray(types.BaseType(form)); ok {
+		t, err := tsType(options, elem)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(t, "|") {
+			return "(" + t + ")[]", nil
+		}
+		return t + "[]", nil
+	}
+	switch types.BaseType(form) {
+	case "int", "long", "float", "number":
+		return "number", nil
+	case "bool":
+		return "boolean", nil
+	case "enum":
+		name, _ := types.ParseEnum(form)
+		return util.CamelCase(name), nil
+	case "array":
+		// 以JSON数组填写时元素可以是任意JSON值
+		return "unknown[]", nil
+	case "object":
+		return "Record<string, unknown>", nil
+	case "map<string>":
+		return "Record<string, string>", nil
+	case "pair":
+		return "{ x: number; y: number }", nil
+	case "triple":
+		return "{ x: number; y: number; z: number }", nil
+	case "string", "date":
+		return "string", nil
+	default:
+		return "", fmt.Errorf("unknown type %s", form)
+	}
+}
+
+// tsEnums 生成枚举定义，枚举导出为名称时为字符串枚举
+func tsEnums(options *Options) []byte {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by excel-tools. DO NOT EDIT.\n")
+	for _, enum := range options.Enums {
+		fmt.Fprintf(&b, "\n/** 枚举 %s */\n", enum.Name)
+		fmt.Fprintf(&b, "export declare const enum %s {\n", util.CamelCase(enum.Name))
+		for _, v := range enum.Values {
+			if v.Comment != "" {
+				fmt.Fprintf(&b, "\t/** %s */\n", tsComment(v.Comment))
+			}
+			if options.EnumName {
+				fmt.Fprintf(&b, "\t%s = %q,\n", v.Name, v.Name)
+			} else {
+				fmt.Fprintf(&b, "\t%s = %d,\n", v.Name, v.Value)
+			}
+		}
+		b.WriteString("}\n")
+	}
+	return b.Bytes()
+}
+
+// tsStructs 生成自定义结构体对应的接口，所有字段都是必填的，点号分隔的字段名生成的结构体字段都是可选的
+func tsStructs(options *Options) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by excel-tools. DO NOT EDIT.\n\n")
+	// 结构体都在同一个文件中，只需要导入枚举
+	var forms []string
+	for _, s := range options.Structs {
+		for _, field := range s.Fields {
+			types.Walk(field.Type, func(t string) {
+				if _, ok := types.ParseEnum(t); ok {
+					forms = append(forms, t)
+				}
+			})
+		}
+	}
+	writeTsImports(&b, options, forms)
+	for i, s := range options.Structs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		comment := s.Comment
+		if comment == "" {
+			comment = "自定义类型 " + s.Name
+		}
+		fmt.Fprintf(&b, "/** %s */\n", tsComment(comment))
+		fmt.Fprintf(&b, "export interface %s {\n", util.CamelCase(s.Name))
+		optional := ""
+		if s.Nested {
+			optional = "?"
+		}
+		for _, field := range s.Fields {
+			t, err := tsFieldType(options, field.Type, field.Nullable)
+			if err != nil {
+				return nil, fmt.Errorf("type %s: field %s: %w", s.Name, field.Name, err)
+			}
+			fmt.Fprintf(&b, "\t%s%s: %s;\n", tsName(field.Name), optional, t)
+		}
+		b.WriteString("}\n")
+	}
+	return b.Bytes(), nil
+}
+
+// writeTsImports 导入类型中用到的枚举和自定义结构体
+func writeTsImports(b *bytes.Buffer, options *Options, forms []string) {
+	var enums, structs []string
+	// 按名称精确去重，不同的类型名可能互为前缀，例如Quality和QualityLevel
+	imported := make(map[string]bool)
+	for _, form := range forms {
+		types.Walk(form, func(t string) {
+			if enum, ok := types.ParseEnum(t); ok {
+				if name := util.CamelCase(enum); !imported[name] {
+					imported[name] = true
+					enums = append(enums, name)
+				}
+			} else if s := options.structType(t); s != nil {
+				if name := util.CamelCase(s.Name); !imported[name] {
+					imported[name] = true
+					structs = append(structs, name)
+				}
+			}
+		})
+	}
+	if len(enums) > 0 {
+		fmt.Fprintf(b, "import { %s } from %q;\n", strings.Join(enums, ", "), "./enums")
+	}
+	if len(structs) > 0 {
+		fmt.Fprintf(b, "import { %s } from %q;\n", strings.Join(structs, ", "), "./structs")
+	}
+	if len(enums) > 0 || len(structs) > 0 {
+		b.WriteString("\n")
+	}
+}
+
+// tsName 属性名，不是合法标识符时加引号
+func tsName(name string) string {
+	if identifier.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// tsComment 避免注释内容提前结束文档注释
+func tsComment(note string) string {
+	note = strings.ReplaceAll(note, "*/", "*\\/")
+	return strings.Join(strings.Fields(note), " ")
+}
@@ -0,0 +1,93 @@
+package codegen
+
+import (
+	"excel-tools/export"
+	"excel-tools/types"
+	"testing"
+)
+
+func TestTypeScriptGenerator(t *testing.T) {
+	options := testOptions(t)
+	if err := new(TypeScriptGenerator).Generate(options, testTables()); err != nil {
+		t.Fatal(err)
+	}
+	expectContains(t, "hero.d.ts", readGenerated(t, options.Output, "hero.d.ts"),
+		"import { Quality } from \"./enums\";\nimport { HeroBonus, Reward } from \"./structs\";",
+		"\t/** 编号 第二行 */\n\tid?: number;",
+		"name: string;",
+		"quality?: Quality;",
+		"level: number | null;",
+		"bonus?: HeroBonus;",
+		"rewards?: Reward[];",
+		"attrs?: Record<string, number>;",
+		"values: unknown[];",
+		"export type HeroTable = Hero[] | Hero;",
+	)
+	expectContains(t, "shop.d.ts", readGenerated(t, options.Output, "shop.d.ts"),
+		"tags?: string[];",
+		"export type ShopTable = Record<string, Shop>;",
+	)
+	expectContains(t, "structs.d.ts", readGenerated(t, options.Output, "structs.d.ts"),
+		"/** 奖励 第二行 */\nexport interface Reward {\n\titem: number;",
+		"p?: { x: number; y: number };",
+		"rate?: number | null;",
+	)
+	expectContains(t, "enums.d.ts", readGenerated(t, options.Output, "enums.d.ts"),
+		"export declare const enum Quality {\n\t/** 普通 第二行 */\n\tCommon = 1,",
+	)
+	expectContains(t, "index.d.ts", readGenerated(t, options.Output, "index.d.ts"),
+		"export * from \"./enums\";\nexport * from \"./structs\";\nexport * from \"./hero\";\nexport * from \"./shop\";",
+	)
+}
+
+func TestTypeScriptGeneratorEnumName(t *testing.T) {
+	options := testOptions(t)
+	options.EnumName = true
+	if err := new(TypeScriptGenerator).Generate(options, testTables()); err != nil {
+		t.Fatal(err)
+	}
+	expectContains(t, "enums.d.ts", readGenerated(t, options.Output, "enums.d.ts"),
+		"Common = \"Common\",",
+		"Epic = \"Epic\",",
+	)
+}
+
+func TestTypeScriptGeneratorUnknownType(t *testing.T) {
+	tables := []*Table{{Name: "item", Client: []export.Field{{Name: "kind", Type: "map<int,nope>"}}}}
+	err := new(TypeScriptGenerator).Generate(testOptions(t), tables)
+	if err == nil || err.Error() != "table item: field kind: unknown type nope" {
+		t.Errorf("Generate error = %v, want the table and field of the unknown type", err)
+	}
+	options := testOptions(t)
+	options.Structs[0].Fields[0].Type = "itn"
+	err = new(TypeScriptGenerator).Generate(options, testTables())
+	if err == nil || err.Error() != "type Reward: field item: unknown type itn" {
+		t.Errorf("Generate error = %v, want the type and field of the unknown type", err)
+	}
+}
+
+// TestTypeScriptImports 类型名互为前缀时都需要导入
+func TestTypeScriptImports(t *testing.T) {
+	options := testOptions(t)
+	options.Enums = append(options.Enums, &types.Enum{Name: "QualityLevel", Values: []*types.EnumValue{{Name: "Low", Value: 1}}})
+	options.Structs = append(options.Structs, &types.Struct{Name: "RewardBox", Fields: []*types.StructField{
+		{Name: "level", Type: "enum<QualityLevel>"},
+		{Name: "quality", Type: "enum<Quality>"},
+	}})
+	tables := []*Table{{Name: "chest", Client: []export.Field{
+		{Name: "quality", Type: "enum<Quality>"},
+		{Name: "level", Type: "enum<QualityLevel>[]"},
+		{Name: "reward", Type: "Reward"},
+		{Name: "box", Type: "map<int,RewardBox>"},
+		{Name: "boxes", Type: "RewardBox[]"},
+	}}}
+	if err := new(TypeScriptGenerator).Generate(options, tables); err != nil {
+		t.Fatal(err)
+	}
+	expectContains(t, "chest.d.ts", readGenerated(t, options.Output, "chest.d.ts"),
+		"import { Quality, QualityLevel } from \"./enums\";\nimport { Reward, RewardBox } from \"./structs\";",
+	)
+	expectContains(t, "structs.d.ts", readGenerated(t, options.Output, "structs.d.ts"),
+		"import { QualityLevel, Quality } from \"./enums\";",
+	)
+}
@@ -101,6 +101,12 @@ func Str2Int64WithDefaultValue(val string, defaultVal int64) int64 {
 	}
 }
 
+// Str2Int64E Convert decimal string to int64, return the error when the conversion fails.
+// Prefixes like 0x/0b/0 and underscores are rejected, so "010" is 10 rather than 8.
+func Str2Int64E(val string) (int64, error) {
+	return strconv.ParseInt(val, 10, 64)
+}
+
 // Str2Int32 Convert string to int32, panic when the string is not of a valid int32 format.
 func Str2Int32(val string) int32 {
 	return int32(wrapInt64Result(internalStr2Int(val, 32, false)))
@@ -177,6 +183,12 @@ func Str2IntWithDefaultValue(val string, defaultVal int) int {
 	}
 }
 
+// Str2IntE Convert decimal string to int, return the error when the conversion fails
+func Str2IntE(val string) (int, error) {
+	res, err := strconv.ParseInt(val, 10, 0)
+	return int(res), err
+}
+
 func internalStr2Uint(val string, bitSize int, noPanic bool) (uint64, error) {
 	res, err := strconv.ParseUint(val, 0, bitSize)
 	if err != nil {
@@ -329,6 +341,11 @@ func Str2Float64WithDefaultValue(val string, defaultVal float64) float64 {
 	}
 }
 
+// Str2Float64E Convert string to float64, return the error when the conversion fails
+func Str2Float64E(val string) (float64, error) {
+	return internalStr2Float(val, 64, true)
+}
+
 // Str2Float32 Convert string to float32, panic when the string is not of a valid float32 format.
 func Str2Float32(val string) float32 {
 	return float32(wrapFloat64Result(internalStr2Float(val, 32, false)))
@@ -348,15 +365,30 @@ func Str2Float32WithDefaultValue(val string, defaultVal float32) float32 {
 	}
 }
 
+// Str2Float32E Convert string to float32, return the error when the conversion fails
+func Str2Float32E(val string) (float32, error) {
+	res, err := internalStr2Float(val, 32, true)
+	return float32(res), err
+}
+
 // Str2Bool Convert string to bool, panic when the string is not of a valid bool format
 func Str2Bool(val string) bool {
+	res, err := Str2BoolE(val)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// Str2BoolE Convert string to bool, return the error when the string is not of a valid bool format
+func Str2BoolE(val string) (bool, error) {
 	if val == "1" || val == "true" || val == "True" || val == "TRUE" {
-		return true
+		return true, nil
 	}
 	if val == "0" || val == "false" || val == "False" || val == "FALSE" {
-		return false
+		return false, nil
 	}
-	panic(errors.New("could not resolve bool value:" + val))
+	return false, errors.New("could not resolve bool value:" + val)
 }
 
 // Bool2Int Convert bool to int, never panics
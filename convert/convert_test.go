@@ -0,0 +1,50 @@
+package convert
+
+import "testing"
+
+func TestStr2IntE(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"42", 42, true},
+		{"-7", -7, true},
+		{"+3", 3, true},
+		{"010", 10, true},
+		{"08", 8, true},
+		{"0x10", 0, false},
+		{"0b101", 0, false},
+		{"1_000", 0, false},
+		{"1.5", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Str2IntE(tt.in)
+		if (err == nil) != tt.ok || tt.ok && got != tt.want {
+			t.Errorf("Str2IntE(%q) = %d, %v, want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStr2Int64E(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"9007199254740993", 9007199254740993, true},
+		{"-9223372036854775808", -9223372036854775808, true},
+		{"9223372036854775808", 0, false},
+		{"010", 10, true},
+		{"0b101", 0, false},
+		{"0o17", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Str2Int64E(tt.in)
+		if (err == nil) != tt.ok || tt.ok && got != tt.want {
+			t.Errorf("Str2Int64E(%q) = %d, %v, want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"excel-tools/export"
+	"excel-tools/report"
+	"excel-tools/types"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"strings"
+)
+
+// enumSheetPrefix 枚举定义sheet的前缀，例如 #enum、#enum_item
+const enumSheetPrefix = "#enum"
+
+// enumFile 枚举定义导出的文件名
+const enumFile = "enums"
+
+// IsEnumSheet sheet是否为枚举定义sheet
+func IsEnumSheet(sheet string) bool {
+	return strings.HasPrefix(sheet, enumSheetPrefix)
+}
+
+// LoadEnums 读取枚举定义sheet，第一行为表头，之后每行依次为 枚举名称、枚举值名称、数值、注释
+//
+// 枚举名称为空时沿用上一行的枚举，便于同一个枚举的多个值连续填写或者合并单元格。存在错误时返回false
+func LoadEnums(f *excelize.File, file string, sheet string, reporter *report.Reporter) ([]*types.Enum, bool) {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		reporter.Add(file, sheet, err)
+		return nil, false
+	}
+	var (
+		enums []*types.Enum
+		enum  *types.Enum
+		ok    = true
+	)
+	// 同一个枚举中已出现的名称和数值以及所在的单元格
+	names := make(map[string]string)
+	values := make(map[string]string)
+	cellError := func(col int, row int, field string, form string, err error) {
+		reporter.Add(file, sheet, &report.CellError{
+			File: file, Sheet: sheet, Col: col, Row: row, Field: field, Type: form, Err: err,
+		})
+		ok = false
+	}
+	for rowIndex, row := range rows {
+		if rowIndex == 0 {
+			continue
+		}
+		cells := make([]string, 4)
+		for i := 0; i < len(cells) && i < len(row); i++ {
+			cells[i] = strings.TrimSpace(row[i])
+		}
+		if cells[0] == "" && cells[1] == "" && cells[2] == "" {
+			continue
+		}
+		if cells[0] != "" && (enum == nil || cells[0] != enum.Name) {
+			duplicate := false
+			for _, e := range enums {
+				duplicate = duplicate || e.Name == cells[0]
+			}
+			enum = &types.Enum{Name: cells[0]}
+			if duplicate {
+				// 重复的定义只在这里报告一次，其中的值仍然校验但不返回，避免再报告为和其它sheet重复
+				cellError(0, rowIndex, "enum", "string", fmt.Errorf("enum %s is already defined above", cells[0]))
+			} else {
+				enums = append(enums, enum)
+			}
+			names = make(map[string]string)
+			values = make(map[string]string)
+		}
+		if enum == nil {
+			cellError(0, rowIndex, "enum", "string", errors.New("enum name is empty"))
+			continue
+		}
+		if cells[1] == "" {
+			cellError(1, rowIndex, "name", "string", errors.New("enum value name is empty"))
+			continue
+		}
+		value, err := new(types.IntTypeConvert).Handle(cells[2])
+		if err != nil {
+			cellError(2, rowIndex, "value", "int", err)
+			continue
+		}
+		if first, exists := names[cells[1]]; exists {
+			cellError(1, rowIndex, "name", "string", fmt.Errorf("duplicate name %s in enum %s, first defined at %s", cells[1], enum.Name, first))
+			continue
+		}
+		if first, exists := values[fmt.Sprint(value)]; exists {
+			cellError(2, rowIndex, "value", "int", fmt.Errorf("duplicate value %v in enum %s, first defined at %s", value, enum.Name, first))
+			continue
+		}
+		names[cells[1]] = report.Axis(1, rowIndex)
+		values[fmt.Sprint(value)] = report.Axis(2, rowIndex)
+		enum.Values = append(enum.Values, &types.EnumValue{Name: cells[1], Value: value.(int), Comment: cells[3]})
+	}
+	return enums, ok
+}
+
+// EnumData 枚举定义导出的数据，导出为一个对象，每个枚举为 名称->数值 的对象
+func EnumData(enums []*types.Enum) (fields []export.Field, values []map[string]interface{}) {
+	row := make(map[string]interface{})
+	for _, enum := range enums {
+		fields = append(fields, export.Field{Name: enum.Name, Type: "object"})
+		members := make(map[string]interface{})
+		for _, v := range enum.Values {
+			members[v.Name] = v.Value
+		}
+		row[enum.Name] = members
+	}
+	return fields, []map[string]interface{}{row}
+}
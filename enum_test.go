@@ -0,0 +1,54 @@
+package main
+
+import (
+	"excel-tools/report"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestLoadEnums(t *testing.T) {
+	path := writeWorkbook(t, "enum.xlsx", testSheet{"#enum", [][]interface{}{
+		{"枚举", "名称", "数值", "注释"},
+		{"Quality", "Common", "1", "普通"},
+		{"", "Epic", "4"},
+		{"Color", "Red", "1"},
+		{"", "", "", ""},
+		{"", "Blue", "2"},
+		{"", "Red", "3"},
+		{"", "Black", "1"},
+		{"", "Green", "x"},
+		{"", "", "5"},
+		{"Quality", "Rare", "2"},
+		{"", "Legend", "5"},
+	}})
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reporter := &report.Reporter{}
+	enums, ok := LoadEnums(f, path, "#enum", reporter)
+	if ok {
+		t.Errorf("LoadEnums succeeded, want errors")
+	}
+	// 重复定义的Quality只报告一次并且不返回
+	expectReported(t, reporter,
+		`enum.xlsx!#enum!B7 (field "name", type string): duplicate name Red in enum Color, first defined at B4`,
+		`enum.xlsx!#enum!C8 (field "value", type int): duplicate value 1 in enum Color, first defined at C4`,
+		`enum.xlsx!#enum!C9 (field "value", type int): "x" is not an integer`,
+		`enum.xlsx!#enum!B10 (field "name", type string): enum value name is empty`,
+		`enum.xlsx!#enum!A11 (field "enum", type string): enum Quality is already defined above`,
+	)
+	if reporter.Count() != 5 {
+		t.Errorf("reported %d error(s), want 5:\n%s", reporter.Count(), reported(reporter))
+	}
+	if len(enums) != 2 || enums[0].Name != "Quality" || enums[1].Name != "Color" {
+		t.Fatalf("enums = %v, want Quality and Color", enums)
+	}
+	if v := enums[0].Values; len(v) != 2 || v[0].Name != "Common" || v[0].Comment != "普通" || v[1].Value != 4 {
+		t.Errorf("Quality values = %v", v)
+	}
+	if v := enums[1].Values; len(v) != 2 || v[0].Name != "Red" || v[1].Name != "Blue" {
+		t.Errorf("Color values = %v", v)
+	}
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+type CsvExport struct{}
+
+// Export CSV格式导出，每个sheet一个文件，第一行为字段名，之后每条记录一行
+//
+// 单元格编码规则：
+//   - 数字、字符串按原样输出，布尔输出 true/false
+//   - 缺失的字段输出为空
+//   - 对象、数组、pair、triple等复合值输出为紧凑的JSON文本，例如 {"x":1001,"y":5}、[1001,1002]
+func (*CsvExport) Export(dst string, options *Options, fields []Field, values []map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	dst += ".csv"
+	if err := ensureDir(dst); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if options.Bom {
+		buf.WriteString("\xEF\xBB\xBF")
+	}
+	w := csv.NewWriter(&buf)
+	record := make([]string, len(fields))
+	for i, field := range fields {
+		record[i] = field.Name
+	}
+	if err := w.Write(record); err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	for _, value := range values {
+		for i, field := range fields {
+			cell, err := csvCell(value[field.Name])
+			if err != nil {
+				return fmt.Errorf("%s: field %s: %w", dst, field.Name, err)
+			}
+			record[i] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("%s: %w", dst, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+
+	return ioutil.WriteFile(dst, buf.Bytes(), os.ModePerm)
+}
+
+// csvCell 将单个值编码为单元格文本
+func csvCell(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
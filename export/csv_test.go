@@ -0,0 +1,54 @@
+package export
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCsvExport(t *testing.T) {
+	fields := []Field{
+		{Name: "id", Type: "int"},
+		{Name: "big", Type: "long"},
+		{Name: "rate", Type: "float"},
+		{Name: "ok", Type: "bool"},
+		{Name: "name", Type: "string"},
+		{Name: "ids", Type: "int[]"},
+		{Name: "pos", Type: "pair"},
+		{Name: "level", Type: "int", Nullable: true},
+	}
+	values := []map[string]interface{}{
+		{
+			"id": 1, "big": int64(5000000000), "rate": float32(0.1), "ok": true, "name": "木剑, \"锋利\"",
+			"ids": []interface{}{1, 2}, "pos": map[string]interface{}{"x": 1001, "y": 5}, "level": nil,
+		},
+		// 缺失的字段输出为空
+		{"id": 2, "rate": 1.5, "name": "多行\n文本"},
+	}
+	dst := filepath.Join(t.TempDir(), "item")
+	if err := new(CsvExport).Export(dst, &Options{Bom: true}, fields, values); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(dst + ".csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\xEF\xBB\xBF" +
+		"id,big,rate,ok,name,ids,pos,level\n" +
+		"1,5000000000,0.1,true,\"木剑, \"\"锋利\"\"\",\"[1,2]\",\"{\"\"x\"\":1001,\"\"y\"\":5}\",\n" +
+		"2,,1.5,,\"多行\n文本\",,,\n"
+	if string(data) != want {
+		t.Errorf("csv = %q\nwant %q", data, want)
+	}
+}
+
+// TestCsvExportEmpty 没有数据时不生成文件
+func TestCsvExportEmpty(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "item")
+	if err := new(CsvExport).Export(dst, &Options{}, []Field{{Name: "id", Type: "int"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := filepath.Glob(dst + "*"); len(matches) > 0 {
+		t.Errorf("unexpected output files %v", matches)
+	}
+}
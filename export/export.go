@@ -1,52 +1,156 @@
 package export
 
 import (
+	"bytes"
 	"encoding/json"
+	"excel-tools/types"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
-// FileExport 导出接口
+// Options 导出选项
+type Options struct {
+	// 是否格式化输出
+	Pretty bool
+	// 只有一条记录时是否导出为对象
+	Single bool
+	// csv是否写入UTF-8 BOM，方便Excel直接打开
+	Bom bool
+	// 是否以主键作为键导出对象而不是数组，csv和protobuf不支持
+	Keyed bool
+	// 主键字段
+	Key string
+	// protobuf导出时的包名
+	Package string
+	// 枚举类型是否导出为名称，否则导出为数值
+	EnumName bool
+	// 自定义结构体，protobuf导出时生成对应的嵌套消息
+	Structs []*types.Struct
+}
+
+// Field 导出字段
+type Field struct {
+	// 字段名，即表格第二行
+	Name string
+	// 字段类型，即表格第三行
+	Type string
+	// 字段注释，即表格第一行
+	Note string
+	// 默认值，类型为 int=0 的形式时空单元格使用该值，为nil表示没有默认值
+	Default interface{}
+	// 类型为 int? 的形式时空单元格导出为null
+	Nullable bool
+	// 类型为 int! 的形式时单元格不能为空
+	Required bool
+	// 取值约束，例如 int(1..100)、string(/^[a-z_]+$/)、int{1,2,5}
+	Constraint types.Constraint
+}
+
+// FileExport 导出接口，dst为不带扩展名的输出路径，fields为按列顺序排列的字段
 type FileExport interface {
-	Export(path string, pretty bool, allowSingle bool, data []map[string]interface{})
+	Export(dst string, options *Options, fields []Field, data []map[string]interface{}) error
+}
+
+// ensureDir 确保输出文件所在目录存在
+func ensureDir(dst string) error {
+	path, _ := filepath.Split(dst)
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return os.MkdirAll(path, os.ModePerm)
+	}
+	return nil
 }
 
 type JsonExport struct{}
 
 // Export JSON格式导出
-func (*JsonExport) Export(dst string, pretty bool, allowSingle bool, values []map[string]interface{}) {
+func (*JsonExport) Export(dst string, options *Options, fields []Field, values []map[string]interface{}) error {
 	if len(values) == 0 {
-		return
+		return nil
 	}
-	path, _ := filepath.Split(dst)
-	if _, err := os.Stat(path); err != nil {
-		err := os.MkdirAll(path, os.ModePerm)
-		if err != nil {
-			fmt.Println(dst, err.Error())
-			return
-		}
+	dst += ".json"
+	if err := ensureDir(dst); err != nil {
+		return err
 	}
 	var payload interface{}
-	if allowSingle && len(values) == 1 {
+	if options.Single && len(values) == 1 {
 		payload = values[0]
 	} else {
 		payload = values
 	}
 
-	var data []byte
-	// 是否格式化输出
-	if pretty {
-		data, _ = json.MarshalIndent(payload, "", "\t")
+	var (
+		data []byte
+		err  error
+	)
+	if options.Keyed {
+		data, err = marshalKeyed(options.Key, values)
+		if err == nil && options.Pretty {
+			var buf bytes.Buffer
+			err = json.Indent(&buf, data, "", "\t")
+			data = buf.Bytes()
+		}
+	} else if options.Pretty {
+		// 是否格式化输出
+		data, err = json.MarshalIndent(payload, "", "\t")
 	} else {
-		data, _ = json.Marshal(payload)
+		data, err = json.Marshal(payload)
 	}
-
-	err := ioutil.WriteFile(dst, data, os.ModePerm)
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+
+	return ioutil.WriteFile(dst, data, os.ModePerm)
+}
+
+// marshalKeyed 以主键的值作为键输出JSON对象，保持行的顺序
+func marshalKeyed(key string, values []map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	seen := make(map[string]bool)
+	buf.WriteByte('{')
+	for i, value := range values {
+		k, err := keyString(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if seen[k] {
+			return nil, fmt.Errorf("row %d: duplicate primary key %s", i+1, k)
+		}
+		seen[k] = true
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(k)
+		row, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(row)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// keyString 行的主键值
+func keyString(key string, value map[string]interface{}) (string, error) {
+	switch v := value[key].(type) {
+	case nil:
+		return "", fmt.Errorf("missing primary key %q", key)
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	default:
+		return "", fmt.Errorf("unsupported primary key type %T", v)
 	}
 }
 
@@ -54,12 +158,18 @@ type FileExportFactory struct {
 }
 
 // GetExport 使用 FileExportFactory获得导出对象
-func (*FileExportFactory) GetExport(format string) (export FileExport) {
+func (*FileExportFactory) GetExport(format string) (export FileExport, err error) {
 	switch format {
 	case "json":
 		export = new(JsonExport)
+	case "csv":
+		export = new(CsvExport)
+	case "lua":
+		export = new(LuaExport)
+	case "protobuf":
+		export = new(ProtobufExport)
 	default:
-		panic(fmt.Sprintf("no such export for %s", format))
+		err = fmt.Errorf("no such export for %s", format)
 	}
 	return
 }
@@ -0,0 +1,288 @@
+package export
+
+import (
+	"bytes"
+	"excel-tools/types"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// luaIdentifier 合法的Lua标识符，可以直接作为表的键 name = value
+var luaIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// luaKeywords Lua保留字，不能直接作为键使用
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "goto": true, "if": true, "in": true,
+	"local": true, "nil": true, "not": true, "or": true, "repeat": true, "return": true,
+	"then": true, "true": true, "until": true, "while": true,
+}
+
+type LuaExport struct{}
+
+// Export Lua格式导出，生成 return { ... } 形式的模块，可以直接 require 使用
+//
+// 默认导出为数组，设置了 Options.Keyed 时以主键的值作为键导出，例如 { [1001] = { id = 1001, ... } }
+func (*LuaExport) Export(dst string, options *Options, fields []Field, values []map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	dst += ".lua"
+	if err := ensureDir(dst); err != nil {
+		return err
+	}
+
+	w := &luaWriter{pretty: options.Pretty, fields: fields, structs: options.Structs}
+	w.buf.WriteString("return ")
+	var err error
+	if options.Keyed {
+		err = w.writeKeyed(options.Key, values)
+	} else if options.Single && len(values) == 1 {
+		err = w.writeRow(values[0], 0)
+	} else {
+		err = w.writeRows(values)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	w.buf.WriteString("\n")
+
+	return ioutil.WriteFile(dst, w.buf.Bytes(), os.ModePerm)
+}
+
+// luaWriter Lua表序列化
+type luaWriter struct {
+	buf    bytes.Buffer
+	pretty bool
+	// 行字段顺序，保证输出和表格列顺序一致
+	fields []Field
+	// 自定义结构体，用于查找结构体字段的类型
+	structs []*types.Struct
+}
+
+// writeRows 按数组输出所有行
+func (w *luaWriter) writeRows(values []map[string]interface{}) error {
+	w.buf.WriteString("{")
+	for i, value := range values {
+		w.newline(1)
+		if err := w.writeRow(value, 1); err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		w.buf.WriteString(",")
+	}
+	w.end(0, len(values) > 0)
+	return nil
+}
+
+// writeKeyed 以指定字段的值作为键输出所有行
+func (w *luaWriter) writeKeyed(key string, values []map[string]interface{}) error {
+	seen := make(map[string]bool)
+	w.buf.WriteString("{")
+	for i, value := range values {
+		id, ok := value[key]
+		if !ok {
+			return fmt.Errorf("row %d: missing key field %q", i+1, key)
+		}
+		k, err := luaKey(id)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if seen[k] {
+			return fmt.Errorf("row %d: duplicate key %v", i+1, id)
+		}
+		seen[k] = true
+		w.newline(1)
+		w.buf.WriteString(k)
+		w.assign()
+		if err := w.writeRow(value, 1); err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		w.buf.WriteString(",")
+	}
+	w.end(0, len(values) > 0)
+	return nil
+}
+
+// writeRow 按字段顺序输出一行
+func (w *luaWriter) writeRow(value map[string]interface{}, depth int) error {
+	w.buf.WriteString("{")
+	for _, field := range w.fields {
+		v, ok := value[field.Name]
+		if !ok || v == nil {
+			continue
+		}
+		w.newline(depth + 1)
+		w.buf.WriteString(luaName(field.Name))
+		w.assign()
+		if err := w.writeValue(v, field.Type, depth+1); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		w.buf.WriteString(",")
+	}
+	w.end(depth, true)
+	return nil
+}
+
+// writeValue 按类型输出任意值，对象的键按字典序输出保证结果稳定，form为空表示类型未知(例如array的元素)
+func (w *luaWriter) writeValue(value interface{}, form string, depth int) error {
+	switch v := value.(type) {
+	case nil:
+		w.buf.WriteString("nil")
+	case string:
+		w.buf.WriteString(luaString(v))
+	case bool:
+		w.buf.WriteString(strconv.FormatBool(v))
+	case int:
+		w.buf.WriteString(strconv.Itoa(v))
+	case int64:
+		w.buf.WriteString(strconv.FormatInt(v, 10))
+	case float32:
+		w.buf.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	case float64:
+		w.buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case []interface{}:
+		elem, _ := types.ParseArray(types.BaseType(form))
+		w.buf.WriteString("{")
+		for _, item := range v {
+			w.newline(depth + 1)
+			if err := w.writeValue(item, elem, depth+1); err != nil {
+				return err
+			}
+			w.buf.WriteString(",")
+		}
+		w.end(depth, len(v) > 0)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		keyForm, valueForm, isMap := types.ParseMap(form)
+		w.buf.WriteString("{")
+		for _, key := range keys {
+			w.newline(depth + 1)
+			if isMap && keyForm != "string" {
+				// map<int,V>的键为整数，输出为 [1001] 而不是 ["1001"]
+				k, err := strconv.ParseInt(key, 10, 64)
+				if err != nil {
+					return fmt.Errorf("key %s: expect integer", key)
+				}
+				w.buf.WriteString("[" + strconv.FormatInt(k, 10) + "]")
+			} else {
+				w.buf.WriteString(luaName(key))
+			}
+			w.assign()
+			if err := w.writeValue(v[key], w.memberType(form, valueForm, key), depth+1); err != nil {
+				return err
+			}
+			w.buf.WriteString(",")
+		}
+		w.end(depth, len(keys) > 0)
+	default:
+		return fmt.Errorf("unsupported value type %T", value)
+	}
+	return nil
+}
+
+// memberType 对象成员的类型，map为值类型，自定义结构体为字段类型，其它对象的成员类型未知
+func (w *luaWriter) memberType(form string, valueForm string, key string) string {
+	if valueForm != "" {
+		return valueForm
+	}
+	for _, s := range w.structs {
+		if s.Name != form {
+			continue
+		}
+		if field, ok := s.Field(key); ok {
+			return field.Type
+		}
+	}
+	return ""
+}
+
+// newline 格式化输出时换行并缩进
+func (w *luaWriter) newline(depth int) {
+	if !w.pretty {
+		return
+	}
+	w.buf.WriteString("\n")
+	w.buf.WriteString(strings.Repeat("\t", depth))
+}
+
+// end 结束一个表，紧凑输出时去掉最后一个元素后的逗号
+func (w *luaWriter) end(depth int, wrap bool) {
+	if w.pretty {
+		if wrap {
+			w.newline(depth)
+		}
+	} else if bytes.HasSuffix(w.buf.Bytes(), []byte(",")) {
+		w.buf.Truncate(w.buf.Len() - 1)
+	}
+	w.buf.WriteString("}")
+}
+
+// assign 输出赋值符号
+func (w *luaWriter) assign() {
+	if w.pretty {
+		w.buf.WriteString(" = ")
+	} else {
+		w.buf.WriteString("=")
+	}
+}
+
+// luaName 表的键，合法标识符直接输出，否则输出为 ["key"]
+func luaName(name string) string {
+	if luaIdentifier.MatchString(name) && !luaKeywords[name] {
+		return name
+	}
+	return "[" + luaString(name) + "]"
+}
+
+// luaKey 主键作为表的键，数字输出为 [1001]，字符串输出为 ["key"]
+func luaKey(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return "[" + luaString(v) + "]", nil
+	case int:
+		return "[" + strconv.Itoa(v) + "]", nil
+	case int64:
+		return "[" + strconv.FormatInt(v, 10) + "]", nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", value)
+	}
+}
+
+// luaString 输出带转义的Lua字符串，非ASCII字符按原样输出
+func luaString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				// 其它控制字符使用十进制转义，补齐3位避免和后续数字连在一起
+				fmt.Fprintf(&b, `\%03d`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
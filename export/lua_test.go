@@ -0,0 +1,135 @@
+package export
+
+import (
+	"excel-tools/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportLua 导出并读取生成的Lua文件
+func exportLua(t *testing.T, options *Options, fields []Field, values []map[string]interface{}) string {
+	t.Helper()
+	dst := filepath.Join(t.TempDir(), "item")
+	if err := new(LuaExport).Export(dst, options, fields, values); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(dst + ".lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLuaExport(t *testing.T) {
+	fields := []Field{
+		{Name: "id", Type: "int"},
+		{Name: "name", Type: "string"},
+		{Name: "end", Type: "bool"},
+		{Name: "rate", Type: "float"},
+		{Name: "ids", Type: "array"},
+		{Name: "attrs", Type: "object"},
+		{Name: "level", Type: "int", Nullable: true},
+	}
+	values := []map[string]interface{}{
+		{
+			"id": 1, "name": "木剑\n\"锋利\"\x01", "end": true, "rate": float32(0.1),
+			"ids": []interface{}{1001.0, "a"}, "attrs": map[string]interface{}{"def": 5, "a-b": 1}, "level": nil,
+		},
+		{"id": 2, "ids": []interface{}{}},
+	}
+	// 字段按列顺序输出，对象的键按字典序输出，保留字和非标识符的键加方括号，null不输出
+	want := `return {{id=1,name="木剑\n\"锋利\"\001",["end"]=true,rate=0.1,ids={1001,"a"},attrs={["a-b"]=1,def=5}},{id=2,ids={}}}` + "\n"
+	if got := exportLua(t, &Options{}, fields, values); got != want {
+		t.Errorf("lua = %s\nwant %s", got, want)
+	}
+
+	want = strings.Join([]string{
+		"return {",
+		"\t{",
+		"\t\tid = 2,",
+		"\t\tids = {},",
+		"\t},",
+		"}",
+		"",
+	}, "\n")
+	if got := exportLua(t, &Options{Pretty: true}, fields, values[1:]); got != want {
+		t.Errorf("pretty lua = %s\nwant %s", got, want)
+	}
+	if got := exportLua(t, &Options{Single: true}, fields, values[1:]); got != "return {id=2,ids={}}\n" {
+		t.Errorf("single lua = %s", got)
+	}
+}
+
+func TestLuaExportKeyed(t *testing.T) {
+	fields := []Field{{Name: "id", Type: "int"}, {Name: "name", Type: "string"}}
+	values := []map[string]interface{}{{"id": 1001, "name": "a"}, {"id": int64(5000000000), "name": "b"}}
+	want := `return {[1001]={id=1001,name="a"},[5000000000]={id=5000000000,name="b"}}` + "\n"
+	if got := exportLua(t, &Options{Keyed: true, Key: "id"}, fields, values); got != want {
+		t.Errorf("lua = %s\nwant %s", got, want)
+	}
+
+	fields = []Field{{Name: "key", Type: "string"}}
+	values = []map[string]interface{}{{"key": "a b"}}
+	want = `return {["a b"]={key="a b"}}` + "\n"
+	if got := exportLua(t, &Options{Keyed: true, Key: "key"}, fields, values); got != want {
+		t.Errorf("lua = %s\nwant %s", got, want)
+	}
+}
+
+func TestLuaExportErrors(t *testing.T) {
+	fields := []Field{{Name: "id", Type: "int"}}
+	tests := []struct {
+		name   string
+		values []map[string]interface{}
+		want   string
+	}{
+		{"missing key", []map[string]interface{}{{"id": 1}, {}}, `row 2: missing key field "id"`},
+		{"duplicate key", []map[string]interface{}{{"id": 1}, {"id": 1}}, "row 2: duplicate key 1"},
+		{"key type", []map[string]interface{}{{"id": 1.5}}, "row 1: unsupported key type float64"},
+	}
+	for _, tt := range tests {
+		dst := filepath.Join(t.TempDir(), "item")
+		err := new(LuaExport).Export(dst, &Options{Keyed: true, Key: "id"}, fields, tt.values)
+		if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestLuaExportMapKeys map<int,V>和map<long,V>的键输出为整数，包括嵌套在数组、map以及结构体中的map
+func TestLuaExportMapKeys(t *testing.T) {
+	bonus := &types.Struct{Name: "HeroBonus", Nested: true, Fields: []*types.StructField{
+		{Name: "attrs", Type: "map<int,int>"},
+	}}
+	fields := []Field{
+		{Name: "attrs", Type: "map<int,float>"},
+		{Name: "big", Type: "map<long,int>"},
+		{Name: "names", Type: "map<string,int>"},
+		{Name: "nested", Type: "map<int,map<int,int>>"},
+		{Name: "list", Type: "map<int,int>[]"},
+		{Name: "bonus", Type: "HeroBonus"},
+		{Name: "object", Type: "object"},
+	}
+	values := []map[string]interface{}{{
+		"attrs":  map[string]interface{}{"1": float32(1.5), "-2": float32(2)},
+		"big":    map[string]interface{}{"5000000000": 1},
+		"names":  map[string]interface{}{"1": 1, "end": 2},
+		"nested": map[string]interface{}{"1": map[string]interface{}{"2": 3}},
+		"list":   []interface{}{map[string]interface{}{"1": 2}},
+		"bonus":  map[string]interface{}{"attrs": map[string]interface{}{"7": 8}},
+		"object": map[string]interface{}{"1": 2},
+	}}
+	want := `return {{attrs={[-2]=2,[1]=1.5},big={[5000000000]=1},names={["1"]=1,["end"]=2},` +
+		`nested={[1]={[2]=3}},list={{[1]=2}},bonus={attrs={[7]=8}},object={["1"]=2}}}` + "\n"
+	if got := exportLua(t, &Options{Structs: []*types.Struct{bonus}}, fields, values); got != want {
+		t.Errorf("lua = %s\nwant %s", got, want)
+	}
+
+	dst := filepath.Join(t.TempDir(), "item")
+	values = []map[string]interface{}{{"attrs": map[string]interface{}{"a": float32(1)}}}
+	if err := new(LuaExport).Export(dst, &Options{}, fields, values); err == nil || !strings.HasSuffix(err.Error(), "key a: expect integer") {
+		t.Errorf("error = %v, want the bad map key", err)
+	}
+}
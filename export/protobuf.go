@@ -0,0 +1,560 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"excel-tools/types"
+	"excel-tools/util"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// protoIdentifier 合法的protobuf标识符
+var protoIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// protobuf线上编码的类型
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoMessage protobuf消息定义
+type protoMessage struct {
+	name   string
+	fields []*protoField
+}
+
+// protoField protobuf字段定义
+type protoField struct {
+	name   string
+	number int
+	// 标量类型，例如 int32、string，嵌套消息时为空
+	kind     string
+	repeated bool
+	// 是否为 map<keyKind, kind>
+	isMap bool
+	// map的键类型，为空时为string
+	keyKind string
+	// 嵌套消息，pair/triple使用
+	message *protoMessage
+	// 可以为null的标量字段，声明为optional以区分未设置和零值
+	optional bool
+	// 字段注释
+	note string
+}
+
+// pairMessage pair类型对应的嵌套消息
+var pairMessage = &protoMessage{name: "Pair", fields: []*protoField{
+	{name: "x", number: 1, kind: "int32"},
+	{name: "y", number: 2, kind: "int32"},
+}}
+
+// tripleMessage triple类型对应的嵌套消息
+var tripleMessage = &protoMessage{name: "Triple", fields: []*protoField{
+	{name: "x", number: 1, kind: "int32"},
+	{name: "y", number: 2, kind: "int32"},
+	{name: "z", number: 3, kind: "int32"},
+}}
+
+type ProtobufExport struct{}
+
+// Export Protobuf格式导出，每个sheet生成一个 .proto 描述文件以及一个 .pb 二进制数据文件
+//
+// 每个sheet对应一个消息，字段编号按列顺序从1开始，数据文件为 <Sheet>Table { repeated <Sheet> rows = 1; } 序列化后的内容。
+// 类型映射：int→int32，long→int64，float→float，number→double，bool→bool，string/date→string，
+// array/int[]→repeated int32，long[]/float[]/bool[]→repeated int64/float/bool，string[]/date[]→repeated string，
+// pair[]/triple[]→repeated Pair/Triple，自定义结构体→对应的嵌套消息，object→map<string,int32>，map<string>→map<string,string>，map<K,V>→map<K,V>(V只支持标量)，
+// pair/triple→嵌套消息 Pair{x,y}/Triple{x,y,z}，enum→int32，导出枚举名称时为string。
+func (*ProtobufExport) Export(dst string, options *Options, fields []Field, values []map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if err := ensureDir(dst); err != nil {
+		return err
+	}
+	_, sheet := filepath.Split(dst)
+	message, err := newProtoMessage(sheet, fields, options)
+	if err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+
+	var data []byte
+	for i, value := range values {
+		row, err := message.encode(nil, value)
+		if err != nil {
+			return fmt.Errorf("%s: row %d: %w", dst, i+1, err)
+		}
+		data = appendTag(data, 1, wireBytes)
+		data = appendVarint(data, uint64(len(row)))
+		data = append(data, row...)
+	}
+
+	if err := ioutil.WriteFile(dst+".proto", message.schema(options.Package), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst+".pb", data, os.ModePerm)
+}
+
+// newProtoMessage 根据sheet名称和字段生成消息定义，枚举导出为名称时按字符串处理，否则按int32处理
+func newProtoMessage(sheet string, fields []Field, options *Options) (*protoMessage, error) {
+	t := &protoTypes{
+		structs:  make(map[string]*types.Struct),
+		messages: make(map[string]*protoMessage),
+		enumName: options.EnumName,
+	}
+	for _, s := range options.Structs {
+		t.structs[s.Name] = s
+	}
+	name := util.CamelCase(sheet)
+	if !protoIdentifier.MatchString(name) {
+		return nil, fmt.Errorf("sheet name %q is not a valid protobuf message name", sheet)
+	}
+	message := &protoMessage{name: name}
+	for i, field := range fields {
+		f, err := t.field(field.Name, field.Type, i+1)
+		if err != nil {
+			return nil, err
+		}
+		f.note, f.optional = field.Note, field.Nullable
+		message.fields = append(message.fields, f)
+	}
+	return message, nil
+}
+
+// protoTypes 生成消息定义时用到的自定义结构体，结构体对应的嵌套消息按需生成
+type protoTypes struct {
+	structs  map[string]*types.Struct
+	messages map[string]*protoMessage
+	enumName bool
+}
+
+// field 按表格类型生成字段定义
+func (t *protoTypes) field(name string, form string, number int) (*protoField, error) {
+	if !protoIdentifier.MatchString(name) {
+		return nil, fmt.Errorf("field name %q is not a valid protobuf field name", name)
+	}
+	f := &protoField{name: name, number: number}
+	if key, value, ok := types.ParseMap(form); ok {
+		// protobuf的map值不能是repeated或者map，只支持标量
+		kind, ok := protoKind(value, t.enumName)
+		if !ok {
+			return nil, fmt.Errorf("field %s: map value type %s is not supported, expect a scalar type", name, value)
+		}
+		f.keyKind, _ = protoKind(key, t.enumName)
+		f.kind, f.isMap = kind, true
+		return f, nil
+	}
+	if elem, ok := types.ParseArray(types.BaseType(form)); ok {
+		// 数组的元素只支持标量、pair/triple以及自定义结构体
+		f.repeated = true
+		if elem == "pair" {
+			f.message = pairMessage
+		} else if elem == "triple" {
+			f.message = tripleMessage
+		} else if t.structs[elem] != nil {
+			message, err := t.message(t.structs[elem])
+			if err != nil {
+				return nil, err
+			}
+			f.message = message
+		} else if f.kind, ok = protoKind(elem, t.enumName); !ok {
+			return nil, fmt.Errorf("field %s: array element type %s is not supported, expect a scalar type, pair, triple or custom type", name, elem)
+		}
+		return f, nil
+	}
+	switch types.BaseType(form) {
+	case "array":
+		f.kind, f.repeated = "int32", true
+	case "object":
+		f.kind, f.isMap = "int32", true
+	case "map<string>":
+		f.kind, f.isMap = "string", true
+	case "pair":
+		f.message = pairMessage
+	case "triple":
+		f.message = tripleMessage
+	default:
+		if t.structs[form] != nil {
+			message, err := t.message(t.structs[form])
+			if err != nil {
+				return nil, err
+			}
+			f.message = message
+			break
+		}
+		kind, ok := protoKind(form, t.enumName)
+		if !ok {
+			return nil, fmt.Errorf("field %s: type %s is not supported", name, form)
+		}
+		f.kind = kind
+	}
+	return f, nil
+}
+
+// message 自定义结构体对应的嵌套消息，字段编号按声明顺序从1开始
+func (t *protoTypes) message(s *types.Struct) (*protoMessage, error) {
+	if message, ok := t.messages[s.Name]; ok {
+		return message, nil
+	}
+	message := &protoMessage{name: util.CamelCase(s.Name)}
+	for i, field := range s.Fields {
+		f, err := t.field(field.Name, field.Type, i+1)
+		if err != nil {
+			return nil, fmt.Errorf("type %s: %w", s.Name, err)
+		}
+		f.optional = field.Nullable
+		message.fields = append(message.fields, f)
+	}
+	t.messages[s.Name] = message
+	return message, nil
+}
+
+// protoKind 标量类型对应的protobuf类型，不是标量时返回false
+func protoKind(form string, enumName bool) (string, bool) {
+	switch types.BaseType(form) {
+	case "int":
+		return "int32", true
+	case "long":
+		return "int64", true
+	case "float":
+		return "float", true
+	case "number":
+		return "double", true
+	case "bool":
+		return "bool", true
+	case "enum":
+		if enumName {
+			return "string", true
+		}
+		return "int32", true
+	case "string", "date":
+		return "string", true
+	default:
+		return "", false
+	}
+}
+
+// schema 生成 .proto 描述文件
+func (m *protoMessage) schema(pkg string) []byte {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by excel-tools. DO NOT EDIT.\n\n")
+	b.WriteString("syntax = \"proto3\";\n\n")
+	fmt.Fprintf(&b, "package %s;\n\n", pkg)
+
+	fmt.Fprintf(&b, "message %s {\n", m.name)
+	// 只声明用到的嵌套消息，嵌套消息中用到的消息同样声明在表的消息中
+	for _, nested := range m.nested(nil) {
+		fmt.Fprintf(&b, "\tmessage %s {\n", nested.name)
+		for _, nf := range nested.fields {
+			fmt.Fprintf(&b, "\t\t%s %s = %d;\n", nf.typeName(), nf.name, nf.number)
+		}
+		b.WriteString("\t}\n")
+	}
+	for _, f := range m.fields {
+		if f.note != "" {
+			// 注释可能有多行，每一行都需要以//开头
+			for _, line := range strings.Split(strings.TrimSpace(f.note), "\n") {
+				fmt.Fprintf(&b, "\t// %s\n", strings.TrimSpace(line))
+			}
+		}
+		fmt.Fprintf(&b, "\t%s %s = %d;\n", f.typeName(), f.name, f.number)
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "message %sTable {\n", m.name)
+	fmt.Fprintf(&b, "\trepeated %s rows = 1;\n", m.name)
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// nested 消息用到的所有嵌套消息，被引用的消息排在前面
+func (m *protoMessage) nested(messages []*protoMessage) []*protoMessage {
+	for _, f := range m.fields {
+		if f.message == nil {
+			continue
+		}
+		declared := false
+		for _, message := range messages {
+			declared = declared || message == f.message
+		}
+		if !declared {
+			messages = append(f.message.nested(messages), f.message)
+		}
+	}
+	return messages
+}
+
+// typeName 字段在 .proto 中的类型声明
+func (f *protoField) typeName() string {
+	switch {
+	case f.message != nil && f.repeated:
+		return "repeated " + f.message.name
+	case f.message != nil:
+		return f.message.name
+	case f.isMap:
+		keyKind := f.keyKind
+		if keyKind == "" {
+			keyKind = "string"
+		}
+		return "map<" + keyKind + ", " + f.kind + ">"
+	case f.repeated:
+		return "repeated " + f.kind
+	case f.optional:
+		return "optional " + f.kind
+	default:
+		return f.kind
+	}
+}
+
+// encode 按消息定义编码一个对象，缺失的字段不输出
+func (m *protoMessage) encode(buf []byte, value map[string]interface{}) ([]byte, error) {
+	var err error
+	for _, f := range m.fields {
+		v, ok := value[f.name]
+		if !ok || v == nil {
+			continue
+		}
+		buf, err = f.encode(buf, v)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return buf, nil
+}
+
+// encode 编码字段，包括字段的tag
+func (f *protoField) encode(buf []byte, value interface{}) ([]byte, error) {
+	switch {
+	case f.message != nil && f.repeated:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect array, got %T", value)
+		}
+		// repeated消息的每个元素单独编码为一个字段
+		for i, item := range arr {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("element %d: expect object, got %T", i, item)
+			}
+			data, err := f.message.encode(nil, obj)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			buf = appendBytes(appendTag(buf, f.number, wireBytes), data)
+		}
+		return buf, nil
+	case f.message != nil:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect object, got %T", value)
+		}
+		data, err := f.message.encode(nil, obj)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(appendTag(buf, f.number, wireBytes), data), nil
+	case f.isMap:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect object, got %T", value)
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// map的每个键值对编码为 { key = 1; value = 2; } 的消息
+			var entry []byte
+			if f.keyKind == "int32" || f.keyKind == "int64" {
+				k, err := strconv.ParseInt(key, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("key %s: expect integer", key)
+				}
+				entry = appendVarint(appendTag(nil, 1, wireVarint), uint64(k))
+			} else {
+				entry = appendBytes(appendTag(nil, 1, wireBytes), []byte(key))
+			}
+			entry = appendTag(entry, 2, wireOf(f.kind))
+			entry, err := appendScalar(entry, f.kind, obj[key])
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+			buf = appendBytes(appendTag(buf, f.number, wireBytes), entry)
+		}
+		return buf, nil
+	case f.repeated:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect array, got %T", value)
+		}
+		if f.kind == "string" {
+			for i, item := range arr {
+				var err error
+				buf, err = appendScalar(appendTag(buf, f.number, wireBytes), f.kind, item)
+				if err != nil {
+					return nil, fmt.Errorf("element %d: %w", i, err)
+				}
+			}
+			return buf, nil
+		}
+		// proto3 数值类型的repeated字段默认使用packed编码
+		var packed []byte
+		for i, item := range arr {
+			var err error
+			packed, err = appendScalar(packed, f.kind, item)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		return appendBytes(appendTag(buf, f.number, wireBytes), packed), nil
+	default:
+		return appendScalar(appendTag(buf, f.number, wireOf(f.kind)), f.kind, value)
+	}
+}
+
+// wireOf 标量类型对应的编码类型
+func wireOf(kind string) int {
+	switch kind {
+	case "float":
+		return wireFixed32
+	case "double":
+		return wireFixed64
+	case "string":
+		return wireBytes
+	default:
+		return wireVarint
+	}
+}
+
+// appendScalar 编码标量值，不包括tag
+func appendScalar(buf []byte, kind string, value interface{}) ([]byte, error) {
+	switch kind {
+	case "int32":
+		v, err := protoInt(value)
+		if err != nil {
+			return nil, err
+		}
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, fmt.Errorf("%d overflows int32", v)
+		}
+		return appendVarint(buf, uint64(v)), nil
+	case "int64":
+		v, err := protoInt(value)
+		if err != nil {
+			return nil, err
+		}
+		return appendVarint(buf, uint64(v)), nil
+	case "bool":
+		v, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expect bool, got %T", value)
+		}
+		if v {
+			return appendVarint(buf, 1), nil
+		}
+		return appendVarint(buf, 0), nil
+	case "float":
+		v, err := protoFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], math.Float32bits(float32(v)))
+		return append(buf, b[:]...), nil
+	case "double":
+		v, err := protoFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+		return append(buf, b[:]...), nil
+	default:
+		v, err := protoString(value)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(buf, []byte(v)), nil
+	}
+}
+
+// protoInt 转换整数值，JSON解析出来的数字为float64，需要是整数
+func protoInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("expect integer, got %v", value)
+	}
+}
+
+// protoFloat 转换浮点值
+func protoFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("expect number, got %v", value)
+	}
+}
+
+// protoString 转换字符串值，数组元素可能被识别为数字，按原样转换为字符串
+func protoString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("expect string, got %T", value)
+	}
+}
+
+// appendVarint 编码varint
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+// appendTag 编码字段tag
+func appendTag(buf []byte, number int, wire int) []byte {
+	return appendVarint(buf, uint64(number)<<3|uint64(wire))
+}
+
+// appendBytes 编码长度前缀的数据
+func appendBytes(buf []byte, data []byte) []byte {
+	buf = appendVarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
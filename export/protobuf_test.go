@@ -0,0 +1,218 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"excel-tools/types"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// decodeProto 编译导出的 .proto 文件，按其中的 <Sheet>Table 消息解码 .pb 文件，返回protojson输出的对象
+func decodeProto(t *testing.T, dst string, message string) map[string]interface{} {
+	t.Helper()
+	dir, name := filepath.Split(dst)
+	compiler := protocompile.Compiler{Resolver: &protocompile.SourceResolver{ImportPaths: []string{dir}}}
+	files, err := compiler.Compile(context.Background(), name+".proto")
+	if err != nil {
+		t.Fatalf("compile %s.proto: %v", name, err)
+	}
+	descriptor := files[0].Messages().ByName(protoreflect.Name(message + "Table"))
+	if descriptor == nil {
+		t.Fatalf("message %sTable not found in %s.proto", message, name)
+	}
+	data, err := ioutil.ReadFile(dst + ".pb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := dynamicpb.NewMessage(descriptor)
+	if err := proto.Unmarshal(data, table); err != nil {
+		t.Fatalf("unmarshal %s.pb: %v", name, err)
+	}
+	text, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(text, &got); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// TestProtobufExportRoundTrip 导出的数据可以按生成的 .proto 解码，解码后的值和导出的值一致
+func TestProtobufExportRoundTrip(t *testing.T) {
+	reward := &types.Struct{Name: "Reward", Fields: []*types.StructField{
+		{Name: "item", Type: "int"},
+		{Name: "count", Type: "long"},
+		{Name: "pos", Type: "pair"},
+	}}
+	box := &types.Struct{Name: "Box", Fields: []*types.StructField{
+		{Name: "reward", Type: "Reward"},
+		{Name: "weight", Type: "int", Nullable: true},
+	}}
+	options := &Options{Package: "config", Structs: []*types.Struct{reward, box}}
+	fields := []Field{
+		{Name: "id", Type: "int", Note: "编号\r\n第二行"},
+		{Name: "big", Type: "long"},
+		{Name: "rate", Type: "float"},
+		{Name: "score", Type: "number"},
+		{Name: "ok", Type: "bool"},
+		{Name: "name", Type: "string"},
+		{Name: "level", Type: "int", Nullable: true},
+		{Name: "ids", Type: "int[]"},
+		{Name: "longs", Type: "long[]"},
+		{Name: "tags", Type: "string[]"},
+		{Name: "values", Type: "array"},
+		{Name: "pos", Type: "pair"},
+		{Name: "cube", Type: "triple"},
+		{Name: "path", Type: "pair[]"},
+		{Name: "attrs", Type: "object"},
+		{Name: "texts", Type: "map<string>"},
+		{Name: "weights", Type: "map<int,long>"},
+		{Name: "box", Type: "Box"},
+		{Name: "rewards", Type: "Reward[]"},
+	}
+	values := []map[string]interface{}{
+		{
+			"id":      -1,
+			"big":     int64(-5000000000),
+			"rate":    1.5,
+			"score":   -2.25,
+			"ok":      true,
+			"name":    "木剑",
+			"level":   0,
+			"ids":     []interface{}{1, -2, 2147483647, -2147483648},
+			"longs":   []interface{}{int64(-5000000000), int64(1)},
+			"tags":    []interface{}{"a", 1001.0},
+			"values":  []interface{}{1001.0, -1.0},
+			"pos":     map[string]interface{}{"x": -1, "y": 2},
+			"cube":    map[string]interface{}{"x": 1, "y": -2, "z": 3},
+			"path":    []interface{}{map[string]interface{}{"x": 1, "y": 2}, map[string]interface{}{"x": -3, "y": 0}},
+			"attrs":   map[string]interface{}{"atk": 10.0, "def": -5.0},
+			"texts":   map[string]interface{}{"zh": "你好"},
+			"weights": map[string]interface{}{"-1": int64(-5000000000), "2": int64(3)},
+			"box": map[string]interface{}{
+				"reward": map[string]interface{}{"item": 1001, "count": int64(-2), "pos": map[string]interface{}{"x": 0, "y": -7}},
+				"weight": -3,
+			},
+			"rewards": []interface{}{
+				map[string]interface{}{"item": 1, "count": int64(9000000000)},
+				map[string]interface{}{"item": -2},
+			},
+		},
+		{
+			// 可以为null的字段为null时不输出，缺失的字段解码为零值
+			"id":    2,
+			"level": nil,
+			"box":   map[string]interface{}{"reward": map[string]interface{}{"item": 1}},
+		},
+	}
+	dst := filepath.Join(t.TempDir(), "item")
+	if err := new(ProtobufExport).Export(dst, options, fields, values); err != nil {
+		t.Fatal(err)
+	}
+
+	// protojson中int64以及map的键输出为字符串，值为0的optional字段仍然输出
+	want := `{"rows": [
+		{
+			"id": -1, "big": "-5000000000", "rate": 1.5, "score": -2.25, "ok": true, "name": "木剑", "level": 0,
+			"ids": [1, -2, 2147483647, -2147483648], "longs": ["-5000000000", "1"], "tags": ["a", "1001"], "values": [1001, -1],
+			"pos": {"x": -1, "y": 2}, "cube": {"x": 1, "y": -2, "z": 3}, "path": [{"x": 1, "y": 2}, {"x": -3}],
+			"attrs": {"atk": 10, "def": -5}, "texts": {"zh": "你好"}, "weights": {"-1": "-5000000000", "2": "3"},
+			"box": {"reward": {"item": 1001, "count": "-2", "pos": {"y": -7}}, "weight": -3},
+			"rewards": [{"item": 1, "count": "9000000000"}, {"item": -2}]
+		},
+		{"id": 2, "box": {"reward": {"item": 1}}}
+	]}`
+	var expected map[string]interface{}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeProto(t, dst, "Item"); !reflect.DeepEqual(got, expected) {
+		text, _ := json.Marshal(got)
+		t.Errorf("decoded %s\nwant %s", text, want)
+	}
+}
+
+// TestProtobufSchema 生成的 .proto 中字段的声明，数值数组使用proto3默认的packed编码
+func TestProtobufSchema(t *testing.T) {
+	options := &Options{Package: "config", Structs: []*types.Struct{
+		{Name: "Reward", Fields: []*types.StructField{{Name: "pos", Type: "pair"}}},
+	}}
+	fields := []Field{
+		{Name: "id", Type: "int", Note: "编号\n第二行"},
+		{Name: "level", Type: "int", Nullable: true},
+		{Name: "ids", Type: "int[]"},
+		{Name: "weights", Type: "map<int,long>"},
+		{Name: "quality", Type: "enum<Quality>"},
+		{Name: "reward", Type: "Reward"},
+	}
+	message, err := newProtoMessage("item", fields, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := string(message.schema("config"))
+	for _, line := range []string{
+		"message Item {",
+		"\tmessage Pair {",
+		"\tmessage Reward {",
+		"\t\tPair pos = 1;",
+		"\t// 编号",
+		"\t// 第二行",
+		"\tint32 id = 1;",
+		"\toptional int32 level = 2;",
+		"\trepeated int32 ids = 3;",
+		"\tmap<int32, int64> weights = 4;",
+		"\tint32 quality = 5;",
+		"\tReward reward = 6;",
+		"message ItemTable {",
+		"\trepeated Item rows = 1;",
+	} {
+		if !strings.Contains(schema, line+"\n") {
+			t.Errorf("schema missing %q:\n%s", line, schema)
+		}
+	}
+	// 被引用的嵌套消息声明在前
+	if strings.Index(schema, "message Pair") > strings.Index(schema, "message Reward") {
+		t.Errorf("Pair should be declared before Reward:\n%s", schema)
+	}
+}
+
+func TestProtobufExportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		sheet  string
+		fields []Field
+		values []map[string]interface{}
+		want   string
+	}{
+		{"sheet name", "道具", []Field{{Name: "id", Type: "int"}}, nil, "not a valid protobuf message name"},
+		{"field name", "item", []Field{{Name: "a-b", Type: "int"}}, nil, "not a valid protobuf field name"},
+		{"map value", "item", []Field{{Name: "m", Type: "map<int,int[]>"}}, nil, "map value type int[] is not supported"},
+		{"array element", "item", []Field{{Name: "a", Type: "map<string>[]"}}, nil, "array element type map<string> is not supported"},
+		{"int32 overflow", "item", []Field{{Name: "id", Type: "int"}}, []map[string]interface{}{{"id": int64(1) << 40}}, "overflows int32"},
+		{"not an integer", "item", []Field{{Name: "ids", Type: "array"}}, []map[string]interface{}{{"ids": []interface{}{1.5}}}, "1.5 is not an integer"},
+		{"map key", "item", []Field{{Name: "m", Type: "map<int,int>"}}, []map[string]interface{}{{"m": map[string]interface{}{"a": 1}}}, "key a: expect integer"},
+	}
+	for _, tt := range tests {
+		values := tt.values
+		if values == nil {
+			values = []map[string]interface{}{{}}
+		}
+		dst := filepath.Join(t.TempDir(), tt.sheet)
+		err := new(ProtobufExport).Export(dst, &Options{Package: "config"}, tt.fields, values)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
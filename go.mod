@@ -3,8 +3,9 @@ module excel-tools
 go 1.16
 
 require (
+	github.com/bufbuild/protocompile v0.6.0
 	github.com/tidwall/gjson v1.12.1
 	github.com/xuri/excelize/v2 v2.4.1
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
-
+	google.golang.org/protobuf v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"excel-tools/codegen"
 	"excel-tools/export"
+	"excel-tools/report"
 	"excel-tools/types"
 	"excel-tools/util"
 	"fmt"
@@ -9,6 +11,7 @@ import (
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -27,34 +30,206 @@ type Conf struct {
 			Pretty bool
 			// 是否开启单个文件为对象
 			Single bool
+			// csv是否写入UTF-8 BOM
+			Bom bool
+			// 是否以主键作为键导出对象而不是数组
+			Keyed bool
+			// 默认主键字段，sheet没有用*标记主键时使用
+			Key string
+			// protobuf导出时的包名
+			Package string
+			// 枚举类型导出数值(value)还是名称(name)，默认为数值
+			Enum string
 			// 客户端输出目录
 			Client string
 			// 服务端输出目录
 			Server string
 		}
+		// 自定义结构体类型，例如 Reward{itemId:int,count:int}，也可以在 #types sheet 中声明
+		Types []string
+		// 表头布局，为空时使用默认布局：注释、字段名、类型、输出端
+		Header HeaderConf
+		// 工作簿的单独配置，键为工作簿文件名
+		Workbooks map[string]WorkbookConf
+		// sheet的单独配置
+		Sheets map[string]SheetConf
+		// 合并导出的表，键为导出的表名，值为sheet名称或者 工作簿文件名!sheet名称，支持*通配符
+		Merge map[string][]string
+		// 代码生成
+		Codegen []struct {
+			// 语言
+			Lang string
+			// 输出目录
+			Output string
+			// 包名/命名空间
+			Package string
+		}
 	}
 }
 
-// MergeCell 合并单元格
-type MergeCell struct {
-	col   int
-	row   int
-	value string
+// SheetConf sheet的单独配置，键为sheet名称或者 工作簿文件名!sheet名称
+type SheetConf struct {
+	// 是否以主键作为键导出对象，为空则使用全局配置
+	Keyed *bool
+	// 表头布局，为空则使用工作簿或者全局配置
+	Header *HeaderConf
+	// 是否为纵向的键值表，也可以在sheet名称前加@
+	Vertical bool
+	// 导出的表名，为空则使用sheet名称，例如sheet名称为中文时配置英文表名
+	Table string
+}
+
+// WorkbookConf 工作簿的单独配置
+type WorkbookConf struct {
+	// 表头布局，为空则使用全局配置
+	Header *HeaderConf
+}
+
+// HeaderConf 表头布局
+type HeaderConf struct {
+	// 表头每一行的作用，依次对应第1行、第2行...，可选 note 注释、name 字段名、type 类型、out 输出端、
+	// default 默认值、constraint 取值约束，空字符串表示忽略该行
+	Rows []string
+	// 数据开始的行号，从1开始，为0时紧接表头
+	Data int
+}
+
+// defaultHeader 默认表头布局，数据从第5行开始
+var defaultHeader = HeaderConf{Rows: []string{"note", "name", "type", "out"}}
+
+// headerRoles 表头行支持的作用
+var headerRoles = map[string]bool{"note": true, "name": true, "type": true, "out": true, "default": true, "constraint": true}
+
+// HeaderLayout 校验后的表头布局，行号从0开始
+type HeaderLayout struct {
+	// 每种作用所在的行
+	rows map[string]int
+	// 表头的行数
+	Size int
+	// 数据开始的行
+	Data int
+}
+
+// Row 作用所在的行，不存在时返回-1
+func (l *HeaderLayout) Row(role string) int {
+	if row, ok := l.rows[role]; ok {
+		return row
+	}
+	return -1
+}
+
+// Layout 校验表头布局，作用必须是支持的并且不能重复，name和type是必需的
+func (h *HeaderConf) Layout() (*HeaderLayout, error) {
+	layout := &HeaderLayout{rows: make(map[string]int), Size: len(h.Rows)}
+	for i, role := range h.Rows {
+		role = strings.TrimSpace(role)
+		if role == "" {
+			continue
+		}
+		if !headerRoles[role] {
+			return nil, fmt.Errorf("unknown header row role %q at row %d, expect note, name, type, out, default or constraint", role, i+1)
+		}
+		if _, ok := layout.rows[role]; ok {
+			return nil, fmt.Errorf("duplicate header row role %q at row %d", role, i+1)
+		}
+		layout.rows[role] = i
+	}
+	for _, role := range []string{"name", "type"} {
+		if _, ok := layout.rows[role]; !ok {
+			return nil, fmt.Errorf("header row role %q is required", role)
+		}
+	}
+	layout.Data = layout.Size
+	if h.Data > 0 {
+		if h.Data <= layout.Size {
+			return nil, fmt.Errorf("data row %d overlaps the %d header row(s)", h.Data, layout.Size)
+		}
+		layout.Data = h.Data - 1
+	}
+	return layout, nil
+}
+
+// GetHeader 获取sheet的表头布局，sheet配置优先，其次为工作簿配置，最后为全局配置
+func (c *Conf) GetHeader(file string, sheet string) *HeaderConf {
+	if sc := c.GetSheetConf(file, sheet); sc.Header != nil {
+		return sc.Header
+	}
+	if wc, ok := c.Config.Workbooks[filepath.Base(file)]; ok && wc.Header != nil {
+		return wc.Header
+	}
+	if len(c.Config.Header.Rows) > 0 {
+		return &c.Config.Header
+	}
+	return &defaultHeader
+}
+
+// CheckHeaders 校验配置文件中所有的表头布局
+func (c *Conf) CheckHeaders() error {
+	if len(c.Config.Header.Rows) > 0 {
+		if _, err := c.Config.Header.Layout(); err != nil {
+			return fmt.Errorf("conf.yaml: header: %w", err)
+		}
+	}
+	for name, wc := range c.Config.Workbooks {
+		if wc.Header == nil {
+			continue
+		}
+		if _, err := wc.Header.Layout(); err != nil {
+			return fmt.Errorf("conf.yaml: header of workbook %s: %w", name, err)
+		}
+	}
+	for name, sc := range c.Config.Sheets {
+		if sc.Header == nil {
+			continue
+		}
+		if _, err := sc.Header.Layout(); err != nil {
+			return fmt.Errorf("conf.yaml: header of sheet %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// keyedFormats 支持以主键作为键导出对象的导出格式
+var keyedFormats = map[string]bool{"json": true, "lua": true}
+
+// CheckKeyed 校验keyed配置，csv和protobuf无法以主键作为键导出，配置了keyed时报错而不是忽略
+func (c *Conf) CheckKeyed() error {
+	format := c.Config.Output.Format
+	if keyedFormats[format] {
+		return nil
+	}
+	if c.Config.Output.Keyed {
+		return fmt.Errorf("conf.yaml: output: keyed is not supported by format %s, only json and lua support it", format)
+	}
+	for name, sc := range c.Config.Sheets {
+		if sc.Keyed != nil && *sc.Keyed {
+			return fmt.Errorf("conf.yaml: keyed of sheet %s is not supported by format %s, only json and lua support it", name, format)
+		}
+	}
+	return nil
+}
+
+// GetSheetConf 获取sheet的单独配置，工作簿文件名!sheet名称 优先
+func (c *Conf) GetSheetConf(file string, sheet string) SheetConf {
+	if sc, ok := c.Config.Sheets[filepath.Base(file)+"!"+sheet]; ok {
+		return sc
+	}
+	return c.Config.Sheets[sheet]
 }
 
 // ReadConf 读取配置文件
-func ReadConf() Conf {
+func ReadConf() (Conf, error) {
 	var conf Conf
 	f, err := ioutil.ReadFile("conf.yaml")
 	if err != nil {
-		fmt.Println(err.Error())
+		return conf, err
 	}
 	// 将读取的yaml文件解析为struct
 	err = yaml.Unmarshal(f, &conf)
 	if err != nil {
-		fmt.Println(err.Error())
+		return conf, fmt.Errorf("conf.yaml: %w", err)
 	}
-	return conf
+	return conf, nil
 }
 
 // ReadFiles from specified path
@@ -75,19 +250,65 @@ func ReadFiles(path string) ([]string, error) {
 	return s, nil
 }
 
+// fatal 输出无法继续导出的错误并以非0状态码退出
+func fatal(err error) {
+	fmt.Println(err)
+	os.Exit(1)
+}
+
 func main() {
-	conf := ReadConf()
+	conf, err := ReadConf()
+	if err != nil {
+		fatal(err)
+	}
+	if err := conf.CheckHeaders(); err != nil {
+		fatal(err)
+	}
+	if err := conf.CheckMerge(); err != nil {
+		fatal(err)
+	}
 	fmt.Printf("input excel directory: %s, excludes: %s\r\n", conf.Config.Input, conf.Config.Excludes)
 	// 导出工厂
 	exportFactory := export.FileExportFactory{}
-	// 类型工厂
-	typeFactory := types.TypeFactory{}
+	// 错误收集
+	reporter := report.Reporter{}
 	// 根据导出格式获取导出实现对象
-	exp := exportFactory.GetExport(conf.Config.Output.Format)
+	exp, err := exportFactory.GetExport(conf.Config.Output.Format)
+	if err != nil {
+		fatal(err)
+	}
+	if err := conf.CheckKeyed(); err != nil {
+		fatal(err)
+	}
+	options := &export.Options{
+		Pretty:   conf.Config.Output.Pretty,
+		Single:   conf.Config.Output.Single,
+		Bom:      conf.Config.Output.Bom,
+		Key:      conf.Config.Output.Key,
+		Package:  conf.Config.Output.Package,
+		EnumName: conf.Config.Output.Enum == "name",
+	}
+	// 未配置包名时使用默认的config
+	if options.Package == "" {
+		options.Package = "config"
+	}
+
+	// 代码生成
+	generatorFactory := codegen.GeneratorFactory{}
+	var generators []codegen.Generator
+	for _, cg := range conf.Config.Codegen {
+		generator, err := generatorFactory.GetGenerator(cg.Lang)
+		if err != nil {
+			fatal(err)
+		}
+		generators = append(generators, generator)
+	}
+	// 导出成功的表结构，用于代码生成
+	var tables []*codegen.Table
 
 	files, err := ReadFiles(conf.Config.Input)
 	if err != nil {
-		fmt.Println(err.Error())
+		fatal(err)
 	}
 	fmt.Printf("read files: %s\n", files)
 
@@ -104,6 +325,11 @@ func main() {
 		total   = 0
 	)
 
+	// 打开所有工作簿
+	var workbooks []struct {
+		file string
+		f    *excelize.File
+	}
 	for _, file := range files {
 		if len(excludes) > 0 && util.ArrayContainMember(file, excludes) {
 			fmt.Printf("skip file %s\r\n", file)
@@ -112,124 +338,188 @@ func main() {
 
 		f, err := excelize.OpenFile(file)
 		if err != nil {
-			fmt.Println(err)
+			fmt.Printf("open file %s failed\r\n", file)
+			reporter.Add(file, "", err)
+			continue
+		}
+		workbooks = append(workbooks, struct {
+			file string
+			f    *excelize.File
+		}{file, f})
+	}
+
+	// 先读取所有的枚举定义，任意工作簿中的表都可以使用
+	var (
+		enums       []*types.Enum
+		enumsOk     = true
+		typeFactory = &types.TypeFactory{Enums: make(map[string]*types.Enum), EnumName: options.EnumName}
+	)
+	for _, wb := range workbooks {
+		for _, sheet := range wb.f.GetSheetList() {
+			if !IsEnumSheet(sheet) {
+				continue
+			}
+			fmt.Printf("Parse enum sheet: %s, file: %s\r\n", sheet, wb.file)
+			loaded, ok := LoadEnums(wb.f, wb.file, sheet, &reporter)
+			enumsOk = enumsOk && ok
+			for _, enum := range loaded {
+				if typeFactory.Enums[enum.Name] != nil {
+					reporter.Add(wb.file, sheet, fmt.Errorf("enum %s is already defined in another sheet", enum.Name))
+					enumsOk = false
+					continue
+				}
+				typeFactory.Enums[enum.Name] = enum
+				enums = append(enums, enum)
+			}
+		}
+	}
+
+	// 读取所有的自定义结构体，配置文件中声明的优先
+	var structs []*types.Struct
+	typeFactory.Structs = make(map[string]*types.Struct)
+	addStruct := func(file string, sheet string, s *types.Struct) {
+		var err error
+		if typeFactory.Structs[s.Name] != nil {
+			err = fmt.Errorf("type %s is already defined", s.Name)
+		} else if typeFactory.Enums[s.Name] != nil {
+			err = fmt.Errorf("type %s has the same name as an enum", s.Name)
+		} else {
+			for _, field := range s.Fields {
+				if err = typeFactory.Check(field.Type); err != nil {
+					err = fmt.Errorf("type %s: field %s: %w", s.Name, field.Name, err)
+					break
+				}
+			}
+		}
+		if err != nil {
+			reporter.Add(file, sheet, err)
 			return
 		}
+		typeFactory.Structs[s.Name] = s
+		structs = append(structs, s)
+	}
+	for _, decl := range conf.Config.Types {
+		s, err := types.ParseStruct(decl)
+		if err != nil {
+			reporter.Add("conf.yaml", "", err)
+			continue
+		}
+		addStruct("conf.yaml", "", s)
+	}
+	for _, wb := range workbooks {
+		for _, sheet := range wb.f.GetSheetList() {
+			if !IsTypesSheet(sheet) {
+				continue
+			}
+			fmt.Printf("Parse types sheet: %s, file: %s\r\n", sheet, wb.file)
+			loaded, _ := LoadStructs(wb.f, wb.file, sheet, &reporter)
+			for _, s := range loaded {
+				addStruct(wb.file, sheet, s)
+			}
+		}
+	}
 
-		sheets := f.GetSheetList()
-		for _, sheet := range sheets {
+	options.Structs = structs
 
+	// 解析所有的sheet，引用需要在所有工作簿加载完成后校验
+	parser := &SheetParser{Conf: &conf, Options: options, Types: typeFactory, Reporter: &reporter}
+	var sheets []*SheetData
+	// 表头错误的sheet导出的表，合并导出时其它sheet也不导出
+	broken := make(map[string]bool)
+	for _, wb := range workbooks {
+		for _, sheet := range wb.f.GetSheetList() {
 			// 如果sheet页以#号开头表示忽略该sheet
 			if strings.HasPrefix(sheet, "#") {
 				continue
 			}
-			fmt.Printf("Parse sheet: %s, file: %s\r\n", sheet, file)
+			fmt.Printf("Parse sheet: %s, file: %s\r\n", sheet, wb.file)
 			total++
-			rows, err := f.GetRows(sheet)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
-			// 第一行注释
-			notes := rows[0]
-			// 第二行字段名
-			names := rows[1]
-			// 第三行类型
-			forms := rows[2]
-			// 第四行输出端
-			outs := rows[3]
-			// 合并单元格
-			cells, _ := f.GetMergeCells(sheet)
-			// 合并单元格值
-			var mergeValues []MergeCell
-
-			if len(cells) > 0 {
-				for _, cell := range cells {
-					startCol, startRow, _ := excelize.CellNameToCoordinates(cell.GetStartAxis())
-					endCol, endRow, _ := excelize.CellNameToCoordinates(cell.GetEndAxis())
-					for j := startRow - 1; j <= endRow-1; j++ {
-						for i := startCol - 1; i <= endCol-1; i++ {
-							mergeValues = append(mergeValues, MergeCell{
-								i, j, cell.GetCellValue(),
-							})
-						}
-					}
-				}
+			if data := parser.Parse(wb.f, wb.file, sheet); data != nil {
+				sheets = append(sheets, data)
+			} else {
+				broken[conf.TableName(wb.file, sheet)] = true
 			}
+		}
+	}
 
-			// 存储客户端/服务器列表
-			var clients []map[string]interface{}
-			var servers []map[string]interface{}
+	// 校验跨表引用
+	CheckRefs(sheets, &reporter)
 
-			for rowIndex, row := range rows {
-				if rowIndex < 4 {
-					continue
-				}
-				// 列数据
-				client := make(map[string]interface{})
-				server := make(map[string]interface{})
-
-				//做一些特殊单元格为空的修正
-				values := row[0:]
-				size := len(rows[0]) - len(row)
-				if size > 0 {
-					for i := 0; i < size; i++ {
-						values = append(values, "")
-					}
-				}
-
-				for colIndex, value := range values {
-					note := notes[colIndex]
-					// 如果注释标记有#号表示忽略该字段
-					if strings.HasPrefix(note, "#") {
-						continue
-					}
-
-					if value == "" {
-						for _, cell := range mergeValues {
-							if cell.col == colIndex && cell.row == rowIndex {
-								value = cell.value
-								break
-							}
-						}
-					}
-
-					name := strings.TrimSpace(names[colIndex])
-					form := strings.TrimSpace(forms[colIndex])
-
-					if value != "" || (form == "string" || form == "array" || form == "object") {
-						// 类型转换
-						value := typeFactory.GetConvert(form).Handle(value)
-
-						out := outs[colIndex]
-						// 如果列输出类型为空或者包含cs或者sc表示会全部输出
-						if strings.Contains(out, "cs") || strings.Contains(out, "sc") {
-							client[name] = value
-							server[name] = value
-						} else if strings.Contains(out, "c") {
-							client[name] = value
-						} else if strings.Contains(out, "s") {
-							server[name] = value
-						}
-					}
-				}
+	// 合并导出到同一个表的sheet，并校验不同的表不会导出到同一个文件
+	exports := MergeSheets(&conf, sheets, broken, &reporter)
+	CheckTableNames(exports, len(enums) > 0 && enumsOk, &reporter)
 
-				if len(client) > 0 {
-					clients = append(clients, client)
-				}
-				if len(server) > 0 {
-					servers = append(servers, server)
-				}
+	for _, data := range exports {
+		// 存在错误的sheet不导出，避免写出残缺的数据
+		if data.Failed {
+			if len(data.Parts) > 0 {
+				fmt.Printf("table %s has errors, skip export\r\n", data.Name)
+			} else {
+				fmt.Printf("sheet %s has errors, skip export\r\n", data.Sheet)
 			}
+			continue
+		}
 
-			// 写出到文件
-			clientDst := fmt.Sprintf("%s%s%s%s", conf.Config.Output.Client, string(os.PathSeparator), sheet, ".json")
-			serverDst := fmt.Sprintf("%s%s%s%s", conf.Config.Output.Server, string(os.PathSeparator), sheet, ".json")
-			exp.Export(clientDst, conf.Config.Output.Pretty, conf.Config.Output.Single, clients)
-			exp.Export(serverDst, conf.Config.Output.Pretty, conf.Config.Output.Single, servers)
+		// 写出到文件
+		clientDst := fmt.Sprintf("%s%s%s", conf.Config.Output.Client, string(os.PathSeparator), data.Name)
+		serverDst := fmt.Sprintf("%s%s%s", conf.Config.Output.Server, string(os.PathSeparator), data.Name)
+		if err := exp.Export(clientDst, &data.Options, data.ClientFields, data.Clients); err != nil {
+			reporter.Add(data.File, data.Sheet, err)
+			continue
+		}
+		if err := exp.Export(serverDst, &data.Options, data.ServerFields, data.Servers); err != nil {
+			reporter.Add(data.File, data.Sheet, err)
+			continue
+		}
+		tables = append(tables, &codegen.Table{
+			Name: data.Name, Client: data.ClientFields, Server: data.ServerFields,
+			Key: data.Options.Key, Keyed: data.Options.Keyed, Structs: data.Structs,
+		})
+		if len(data.Parts) > 0 {
+			succeed += len(data.Parts)
+		} else {
 			succeed++
 		}
 	}
-	fmt.Println("export finished, enjoy it! :)")
-	fmt.Printf("total: %d, succeed: %d, fail: %d, time consuming: %d(ms)", total, succeed, total-succeed, time.Now().Sub(start).Milliseconds())
+
+	// 导出枚举定义，客户端和服务端共用
+	if len(enums) > 0 && enumsOk {
+		enumOptions := *options
+		enumOptions.Single, enumOptions.Keyed = true, false
+		fields, values := EnumData(enums)
+		for _, dir := range []string{conf.Config.Output.Client, conf.Config.Output.Server} {
+			if err := exp.Export(fmt.Sprintf("%s%s%s", dir, string(os.PathSeparator), enumFile), &enumOptions, fields, values); err != nil {
+				reporter.Add(enumFile, "", err)
+				break
+			}
+		}
+	}
+
+	// 存在错误时不生成代码，避免生成的代码和导出的数据不一致
+	if len(generators) > 0 && !reporter.HasErrors() {
+		// 自定义结构体以及各个表中点号分隔的字段生成的结构体
+		allStructs := append([]*types.Struct(nil), structs...)
+		for _, table := range tables {
+			allStructs = append(allStructs, table.Structs...)
+		}
+		for i, generator := range generators {
+			cg := conf.Config.Codegen[i]
+			fmt.Printf("Generate %s code: %s\r\n", cg.Lang, cg.Output)
+			if err := generator.Generate(&codegen.Options{
+				Output: cg.Output, Package: cg.Package, Enums: enums, EnumName: options.EnumName, Structs: allStructs,
+			}, tables); err != nil {
+				reporter.Add(cg.Output, "", err)
+			}
+		}
+	}
+	reporter.Print(os.Stdout)
+	if reporter.HasErrors() {
+		fmt.Println("export failed :(")
+	} else {
+		fmt.Println("export finished, enjoy it! :)")
+	}
+	fmt.Printf("total: %d, succeed: %d, fail: %d, time consuming: %d(ms)\r\n", total, succeed, total-succeed, time.Now().Sub(start).Milliseconds())
+	if reporter.HasErrors() {
+		os.Exit(1)
+	}
 }
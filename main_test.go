@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"excel-tools/export"
+	"excel-tools/report"
+	"excel-tools/types"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// testSheet 测试用的sheet，rows按行依次填写单元格
+type testSheet struct {
+	name string
+	rows [][]interface{}
+}
+
+// writeWorkbook 在临时目录中生成工作簿，返回文件路径
+func writeWorkbook(t *testing.T, name string, sheets ...testSheet) string {
+	t.Helper()
+	f := excelize.NewFile()
+	for i, sheet := range sheets {
+		if i == 0 {
+			f.SetSheetName(f.GetSheetName(0), sheet.name)
+		} else {
+			f.NewSheet(sheet.name)
+		}
+		for r := range sheet.rows {
+			axis, err := excelize.CoordinatesToCellName(1, r+1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.SetSheetRow(sheet.name, axis, &sheet.rows[r]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testConf 解析yaml格式的配置
+func testConf(t *testing.T, text string) *Conf {
+	t.Helper()
+	var conf Conf
+	if err := yaml.Unmarshal([]byte(text), &conf); err != nil {
+		t.Fatal(err)
+	}
+	return &conf
+}
+
+// testParser 按配置创建sheet解析器，typeFactory为nil时不包含枚举和自定义结构体
+func testParser(conf *Conf, typeFactory *types.TypeFactory) *SheetParser {
+	if typeFactory == nil {
+		typeFactory = &types.TypeFactory{}
+	}
+	options := &export.Options{
+		Key:      conf.Config.Output.Key,
+		Package:  "config",
+		EnumName: conf.Config.Output.Enum == "name",
+	}
+	return &SheetParser{Conf: conf, Options: options, Types: typeFactory, Reporter: &report.Reporter{}}
+}
+
+// parseWorkbook 解析工作簿中所有不以#开头的sheet，按sheet顺序返回，表头错误的sheet为nil
+func parseWorkbook(t *testing.T, parser *SheetParser, path string) []*SheetData {
+	t.Helper()
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheets []*SheetData
+	for _, sheet := range f.GetSheetList() {
+		if !strings.HasPrefix(sheet, "#") {
+			sheets = append(sheets, parser.Parse(f, path, sheet))
+		}
+	}
+	return sheets
+}
+
+// reported 错误报告的文本
+func reported(reporter *report.Reporter) string {
+	var b bytes.Buffer
+	reporter.Print(&b)
+	return b.String()
+}
+
+// expectReported 检查错误报告包含每一条错误
+func expectReported(t *testing.T, reporter *report.Reporter, errs ...string) {
+	t.Helper()
+	text := reported(reporter)
+	for _, err := range errs {
+		if !strings.Contains(text, err) {
+			t.Errorf("report does not contain %q:\n%s", err, text)
+		}
+	}
+}
+
+// expectNoErrors 检查没有报告错误
+func expectNoErrors(t *testing.T, reporter *report.Reporter) {
+	t.Helper()
+	if reporter.HasErrors() {
+		t.Fatalf("unexpected errors:\n%s", reported(reporter))
+	}
+}
+
+// exportJson 以JSON格式导出sheet的服务端数据并返回文件内容
+func exportJson(t *testing.T, data *SheetData) string {
+	t.Helper()
+	dst := filepath.Join(t.TempDir(), data.Name)
+	if err := new(export.JsonExport).Export(dst, &data.Options, data.ServerFields, data.Servers); err != nil {
+		t.Fatal(err)
+	}
+	text, err := ioutil.ReadFile(dst + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
+
+func TestCheckKeyed(t *testing.T) {
+	tests := []struct {
+		conf string
+		want string
+	}{
+		{"config: {output: {format: json, keyed: true}}", ""},
+		{"config: {output: {format: lua}, sheets: {shop: {keyed: true}}}", ""},
+		{"config: {output: {format: csv, keyed: false}, sheets: {shop: {keyed: false}}}", ""},
+		{"config: {output: {format: csv, keyed: true}}", "conf.yaml: output: keyed is not supported by format csv"},
+		{"config: {output: {format: protobuf}, sheets: {shop: {keyed: true}}}", "conf.yaml: keyed of sheet shop is not supported by format protobuf"},
+	}
+	for _, tt := range tests {
+		err := testConf(t, tt.conf).CheckKeyed()
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.want)) {
+			t.Errorf("CheckKeyed(%s) error = %v, want %q", tt.conf, err, tt.want)
+		}
+	}
+}
+
+func TestParseKeyed(t *testing.T) {
+	path := writeWorkbook(t, "shop.xlsx",
+		testSheet{"shop", [][]interface{}{
+			{"编号", "名称", "价格"},
+			{"*sid", "name", "price"},
+			{"int", "string", "long"},
+			{"cs", "cs", "s"},
+			{"1001", "木剑", "10"},
+			{"1002", "铁剑", "5000000000"},
+		}},
+		testSheet{"item", [][]interface{}{
+			{"编号", "名称"},
+			{"id", "name"},
+			{"string", "string"},
+			{"cs", "cs"},
+			{"a", "木剑"},
+		}},
+		testSheet{"array", [][]interface{}{
+			{"编号"},
+			{"id"},
+			{"int"},
+			{"cs"},
+			{"1"},
+		}},
+	)
+	conf := testConf(t, "config: {output: {format: json, keyed: true, key: id}, sheets: {array: {keyed: false}}}")
+	parser := testParser(conf, nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectNoErrors(t, parser.Reporter)
+
+	// *标记的主键优先，没有标记时使用默认主键字段
+	if got, want := exportJson(t, sheets[0]), `{"1001":{"name":"木剑","price":10,"sid":1001},"1002":{"name":"铁剑","price":5000000000,"sid":1002}}`; got != want {
+		t.Errorf("shop = %s, want %s", got, want)
+	}
+	if got, want := exportJson(t, sheets[1]), `{"a":{"id":"a","name":"木剑"}}`; got != want {
+		t.Errorf("item = %s, want %s", got, want)
+	}
+	// sheet单独配置不以主键作为键导出
+	if got, want := exportJson(t, sheets[2]), `[{"id":1}]`; got != want {
+		t.Errorf("array = %s, want %s", got, want)
+	}
+}
+
+func TestParseKeyedErrors(t *testing.T) {
+	path := writeWorkbook(t, "shop.xlsx",
+		testSheet{"missing", [][]interface{}{
+			{"名称"},
+			{"name"},
+			{"string"},
+			{"cs"},
+		}},
+		testSheet{"float", [][]interface{}{
+			{"编号"},
+			{"*id"},
+			{"float"},
+			{"cs"},
+		}},
+		testSheet{"client", [][]interface{}{
+			{"编号", "名称"},
+			{"*id", "name"},
+			{"int", "string"},
+			{"c", "cs"},
+		}},
+	)
+	conf := testConf(t, "config: {output: {format: json, keyed: true, key: id}}")
+	parser := testParser(conf, nil)
+	for i, data := range parseWorkbook(t, parser, path) {
+		if data != nil {
+			t.Errorf("sheet %d parsed, want a header error", i)
+		}
+	}
+	expectReported(t, parser.Reporter,
+		"sheet missing: 1 error(s)\r\n    keyed export requires a primary key column, mark it with * in the name row",
+		`sheet float: 1 error(s)`+"\r\n"+`    primary key "id" has type float, expect int, long or string`,
+		`sheet client: 1 error(s)`+"\r\n"+`    primary key "id" is not exported to server`,
+	)
+}
+
+func TestHeaderLayout(t *testing.T) {
+	tests := []struct {
+		header HeaderConf
+		want   string
+	}{
+		{HeaderConf{Rows: []string{"note", "name", "type", "out"}}, ""},
+		{HeaderConf{Rows: []string{"name", "", "type"}, Data: 5}, ""},
+		{HeaderConf{Rows: []string{"name", "kind"}}, `unknown header row role "kind" at row 2, expect note, name, type, out, default or constraint`},
+		{HeaderConf{Rows: []string{"name", "type", "name"}}, `duplicate header row role "name" at row 3`},
+		{HeaderConf{Rows: []string{"note", "name"}}, `header row role "type" is required`},
+		{HeaderConf{Rows: []string{"name", "type"}, Data: 2}, "data row 2 overlaps the 2 header row(s)"},
+	}
+	for _, tt := range tests {
+		layout, err := tt.header.Layout()
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || err.Error() != tt.want) {
+			t.Errorf("Layout(%v) error = %v, want %q", tt.header, err, tt.want)
+			continue
+		}
+		if err == nil && layout.Row("type") < 0 {
+			t.Errorf("Layout(%v) has no type row", tt.header)
+		}
+	}
+}
+
+func TestGetHeader(t *testing.T) {
+	conf := testConf(t, `config:
+  header: {rows: [name, type, out]}
+  workbooks: {item.xlsx: {header: {rows: [name, type]}}}
+  sheets: {item.xlsx!shop: {header: {rows: [type, name]}}}`)
+	tests := []struct {
+		file  string
+		sheet string
+		want  string
+	}{
+		{"dir/item.xlsx", "shop", "[type name]"},
+		{"dir/item.xlsx", "item", "[name type]"},
+		{"dir/hero.xlsx", "shop", "[name type out]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(conf.GetHeader(tt.file, tt.sheet).Rows); got != tt.want {
+			t.Errorf("GetHeader(%s, %s) = %s, want %s", tt.file, tt.sheet, got, tt.want)
+		}
+	}
+	if got := fmt.Sprint(testConf(t, "config: {}").GetHeader("item.xlsx", "item").Rows); got != "[note name type out]" {
+		t.Errorf("default header = %s", got)
+	}
+	conf = testConf(t, "config: {sheets: {shop: {header: {rows: [name]}}}}")
+	if err := conf.CheckHeaders(); err == nil || err.Error() != `conf.yaml: header of sheet shop: header row role "type" is required` {
+		t.Errorf("CheckHeaders() error = %v", err)
+	}
+}
+
+func TestParseHeaderLayout(t *testing.T) {
+	path := writeWorkbook(t, "hero.xlsx",
+		testSheet{"hero", [][]interface{}{
+			{"id", "lv", "title"},
+			{"int", "int", "string"},
+			{"", "1", ""},
+			{"", "1..10", ""},
+			{"说明", "", ""},
+			{"1", "5", "勇者"},
+			{"2", "", "法师"},
+		}},
+		testSheet{"invalid", [][]interface{}{
+			{"id", "lv"},
+			{"int", "int=1"},
+			{"", "2"},
+			{"", "1..5"},
+		}},
+	)
+	conf := testConf(t, "config: {output: {format: json}, header: {rows: [name, type, default, constraint], data: 6}}")
+	parser := testParser(conf, nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectReported(t, parser.Reporter,
+		`hero.xlsx!invalid!B3 (field "lv", type int): default value is set in both the type row and the default row`,
+	)
+	if got, want := parser.Reporter.Count(), 1; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	// 没有输出端行时客户端和服务端都输出，第5行不属于表头也不是数据
+	want := `[{"id":1,"lv":5,"title":"勇者"},{"id":2,"lv":1,"title":"法师"}]`
+	if got := exportJson(t, sheets[0]); got != want {
+		t.Errorf("hero = %s, want %s", got, want)
+	}
+	if sheets[1] != nil {
+		t.Errorf("invalid sheet parsed, want a header error")
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"excel-tools/export"
+	"excel-tools/report"
+	"excel-tools/types"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KeyCell 主键单元格，合并导出时校验不同sheet之间的主键是否重复
+type KeyCell struct {
+	Col   int
+	Row   int
+	Value string
+}
+
+// MergeTable sheet在merge中声明的表名，表名按字母顺序匹配，没有声明时返回false
+//
+// 声明为sheet名称时匹配所有工作簿中的同名sheet，声明为 工作簿文件名!sheet名称 时只匹配该工作簿，都支持*通配符
+func (c *Conf) MergeTable(file string, sheet string) (string, bool) {
+	tables := make([]string, 0, len(c.Config.Merge))
+	for table := range c.Config.Merge {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		for _, pattern := range c.Config.Merge[table] {
+			name := sheet
+			if strings.Contains(pattern, "!") {
+				name = filepath.Base(file) + "!" + sheet
+			}
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return table, true
+			}
+		}
+	}
+	return "", false
+}
+
+// CheckMerge 校验merge的配置，表名不能为空，sheet的通配符必须合法
+func (c *Conf) CheckMerge() error {
+	for table, patterns := range c.Config.Merge {
+		if strings.TrimSpace(table) == "" {
+			return fmt.Errorf("conf.yaml: merge: table name is empty")
+		}
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("conf.yaml: merge: table %s: invalid sheet pattern %q", table, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// MergeSheets 将导出到同一个表的sheet合并为一个表，按表第一次出现的顺序返回，行按sheet的读取顺序拼接
+//
+// 只有在merge中声明的sheet可以合并，未声明时多个sheet导出到同一个表会报错，避免后读取的sheet覆盖之前的数据。
+// 合并的sheet字段、主键必须一致，主键在所有sheet中不能重复。broken为表头错误没有解析出数据的表，这些表整体不导出
+func MergeSheets(conf *Conf, sheets []*SheetData, broken map[string]bool, reporter *report.Reporter) []*SheetData {
+	var names []string
+	groups := make(map[string][]*SheetData)
+	for _, data := range sheets {
+		if groups[data.Name] == nil {
+			names = append(names, data.Name)
+		}
+		groups[data.Name] = append(groups[data.Name], data)
+	}
+
+	var tables []*SheetData
+	for _, name := range names {
+		parts := groups[name]
+		first := parts[0]
+		if len(parts) == 1 {
+			first.Failed = first.Failed || broken[name]
+			tables = append(tables, first)
+			continue
+		}
+
+		merged := *first
+		merged.Parts = parts
+		merged.Clients, merged.Servers = nil, nil
+		merged.Failed = broken[name]
+		var sheetNames []string
+		for _, part := range parts {
+			sheetNames = append(sheetNames, part.Sheet)
+		}
+		merged.Sheet = strings.Join(sheetNames, ", ")
+
+		// 主键值第一次出现的单元格
+		keys := make(map[string]string)
+		keyField, ok := FindField(first.ClientFields, first.Options.Key)
+		if !ok {
+			keyField, _ = FindField(first.ServerFields, first.Options.Key)
+		}
+		for i, part := range parts {
+			if _, ok := conf.MergeTable(part.File, part.Sheet); !ok {
+				reporter.Add(part.File, part.Sheet, fmt.Errorf("table %s is exported by more than one sheet (%s), declare them in merge of conf.yaml to export them as one table", name, merged.Source()))
+				merged.Failed = true
+				continue
+			}
+			if i > 0 {
+				if err := CheckMergeable(first, part); err != nil {
+					reporter.Add(part.File, part.Sheet, err)
+					merged.Failed = true
+					continue
+				}
+			}
+			for _, cell := range part.Keys {
+				if at, ok := keys[cell.Value]; ok {
+					reporter.Add(part.File, part.Sheet, &report.CellError{
+						File: part.File, Sheet: part.Sheet, Col: cell.Col, Row: cell.Row,
+						Field: keyField.Name, Type: keyField.Type, Err: fmt.Errorf("duplicate primary key %s, first defined at %s", cell.Value, at),
+					})
+					merged.Failed = true
+					continue
+				}
+				keys[cell.Value] = part.Source() + "!" + report.Axis(cell.Col, cell.Row)
+			}
+			merged.Failed = merged.Failed || part.Failed
+			merged.Clients = append(merged.Clients, part.Clients...)
+			merged.Servers = append(merged.Servers, part.Servers...)
+		}
+		tables = append(tables, &merged)
+	}
+	return tables
+}
+
+// CheckMergeable 校验合并到同一个表的sheet的字段和主键是否一致，字段的顺序可以不同
+func CheckMergeable(first *SheetData, data *SheetData) error {
+	source := first.Source()
+	if data.Options.Key != first.Options.Key || data.Options.Keyed != first.Options.Keyed {
+		return fmt.Errorf("primary key %q (keyed: %v) does not match %q (keyed: %v) of %s",
+			data.Options.Key, data.Options.Keyed, first.Options.Key, first.Options.Keyed, source)
+	}
+	sides := []struct {
+		name string
+		want []export.Field
+		got  []export.Field
+	}{
+		{"client", first.ClientFields, data.ClientFields},
+		{"server", first.ServerFields, data.ServerFields},
+	}
+	for _, side := range sides {
+		if err := sameFields(side.want, side.got); err != nil {
+			return fmt.Errorf("%s fields do not match %s: %w", side.name, source, err)
+		}
+	}
+	for _, s := range data.Structs {
+		for _, exists := range first.Structs {
+			if exists.Name == s.Name && !sameStruct(exists, s) {
+				return fmt.Errorf("nested type %s does not match %s", s.Name, source)
+			}
+		}
+	}
+	return nil
+}
+
+// sameFields 比较两组字段，名称、类型、?/! 以及默认值都必须一致
+func sameFields(want []export.Field, got []export.Field) error {
+	for _, w := range want {
+		g, ok := FindField(got, w.Name)
+		if !ok {
+			return fmt.Errorf("field %q is missing", w.Name)
+		}
+		if fieldForm(g) != fieldForm(w) {
+			return fmt.Errorf("field %q has type %s, expect %s", w.Name, fieldForm(g), fieldForm(w))
+		}
+		if fmt.Sprint(g.Default) != fmt.Sprint(w.Default) {
+			return fmt.Errorf("field %q has default value %v, expect %v", w.Name, g.Default, w.Default)
+		}
+	}
+	for _, g := range got {
+		if _, ok := FindField(want, g.Name); !ok {
+			return fmt.Errorf("field %q is not expected", g.Name)
+		}
+	}
+	return nil
+}
+
+// fieldForm 字段的类型以及 ?/! 标记，用于比较和错误信息
+func fieldForm(field export.Field) string {
+	switch {
+	case field.Nullable:
+		return field.Type + "?"
+	case field.Required:
+		return field.Type + "!"
+	default:
+		return field.Type
+	}
+}
+
+// sameStruct 比较两个同名结构体的字段，字段的顺序可以不同
+func sameStruct(a *types.Struct, b *types.Struct) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for _, field := range a.Fields {
+		f, ok := b.Field(field.Name)
+		if !ok || f.Type != field.Type || f.Nullable != field.Nullable {
+			return false
+		}
+	}
+	return true
+}
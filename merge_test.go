@@ -0,0 +1,128 @@
+package main
+
+import "testing"
+
+func TestMergeTable(t *testing.T) {
+	conf := testConf(t, `config:
+  merge:
+    Item: [Item_*]
+    Skill: [skill_a.xlsx!Skill, skill_b.xlsx!Skill]`)
+	tests := []struct {
+		file  string
+		sheet string
+		table string
+		ok    bool
+	}{
+		{"dir/item.xlsx", "Item_weapon", "Item", true},
+		{"dir/item.xlsx", "Item", "", false},
+		{"dir/skill_a.xlsx", "Skill", "Skill", true},
+		{"dir/skill_c.xlsx", "Skill", "", false},
+	}
+	for _, tt := range tests {
+		if table, ok := conf.MergeTable(tt.file, tt.sheet); table != tt.table || ok != tt.ok {
+			t.Errorf("MergeTable(%s, %s) = %s, %v, want %s, %v", tt.file, tt.sheet, table, ok, tt.table, tt.ok)
+		}
+	}
+	if err := testConf(t, "config: {merge: {Item: ['Item_[']}}").CheckMerge(); err == nil || err.Error() != `conf.yaml: merge: table Item: invalid sheet pattern "Item_["` {
+		t.Errorf("CheckMerge() error = %v", err)
+	}
+}
+
+func TestMergeSheets(t *testing.T) {
+	weapon := writeWorkbook(t, "item_a.xlsx", testSheet{"Item_weapon", [][]interface{}{
+		{"编号", "名称"},
+		{"*id", "name"},
+		{"int", "string"},
+		{"cs", "cs"},
+		{"1001", "木剑"},
+	}})
+	armor := writeWorkbook(t, "item_b.xlsx",
+		testSheet{"Item_armor", [][]interface{}{
+			{"名称", "编号"},
+			{"name", "*id"},
+			{"string", "int"},
+			{"cs", "cs"},
+			{"布甲", "2001"},
+		}},
+		testSheet{"Item_ring", [][]interface{}{
+			{"编号", "名称"},
+			{"*id", "name"},
+			{"int", "string"},
+			{"cs", "cs"},
+			{"1001", "戒指"},
+		}},
+	)
+	conf := testConf(t, "config: {output: {format: json}, merge: {Item: [Item_weapon, Item_armor]}}")
+	parser := testParser(conf, nil)
+	sheets := append(parseWorkbook(t, parser, weapon), parseWorkbook(t, parser, armor)...)
+	expectNoErrors(t, parser.Reporter)
+	tables := MergeSheets(conf, sheets, nil, parser.Reporter)
+	expectNoErrors(t, parser.Reporter)
+
+	// 声明的sheet按读取顺序拼接，列的顺序可以不同
+	if len(tables) != 2 || tables[0].Name != "Item" || len(tables[0].Parts) != 2 {
+		t.Fatalf("tables = %d, want Item merged from 2 sheets and Item_ring", len(tables))
+	}
+	if got, want := tables[0].Source(), "item_a.xlsx!Item_weapon, item_b.xlsx!Item_armor"; got != want {
+		t.Errorf("source = %s, want %s", got, want)
+	}
+	if got, want := exportJson(t, tables[0]), `[{"id":1001,"name":"木剑"},{"id":2001,"name":"布甲"}]`; got != want {
+		t.Errorf("Item = %s, want %s", got, want)
+	}
+}
+
+func TestMergeSheetsErrors(t *testing.T) {
+	path := writeWorkbook(t, "item.xlsx",
+		testSheet{"Item_weapon", [][]interface{}{
+			{"编号", "名称"},
+			{"*id", "name"},
+			{"int", "string"},
+			{"cs", "cs"},
+			{"1001", "木剑"},
+		}},
+		testSheet{"Item_armor", [][]interface{}{
+			{"编号", "名称"},
+			{"*id", "name"},
+			{"int", "string"},
+			{"cs", "cs"},
+			{"1001", "布甲"},
+		}},
+		testSheet{"Item_ring", [][]interface{}{
+			{"编号", "名称"},
+			{"*id", "name"},
+			{"int", "int"},
+			{"cs", "cs"},
+		}},
+		testSheet{"Skill", [][]interface{}{
+			{"编号"},
+			{"*id"},
+			{"int"},
+			{"cs"},
+		}},
+	)
+	// 没有在merge中声明的同名sheet导出到同一个表
+	skill := writeWorkbook(t, "skill.xlsx", testSheet{"Skill", [][]interface{}{
+		{"编号"},
+		{"*id"},
+		{"int"},
+		{"cs"},
+	}})
+	conf := testConf(t, `config:
+  output: {format: json}
+  merge: {Item: [Item_*]}`)
+	parser := testParser(conf, nil)
+	sheets := append(parseWorkbook(t, parser, path), parseWorkbook(t, parser, skill)...)
+	expectNoErrors(t, parser.Reporter)
+	tables := MergeSheets(conf, sheets, nil, parser.Reporter)
+	expectReported(t, parser.Reporter,
+		`item.xlsx!Item_armor!A5 (field "id", type int): duplicate primary key 1001, first defined at item.xlsx!Item_weapon!A5`,
+		`client fields do not match item.xlsx!Item_weapon: field "name" has type int, expect string`,
+		`table Skill is exported by more than one sheet (item.xlsx!Skill, skill.xlsx!Skill), declare them in merge of conf.yaml`,
+	)
+	if got, want := parser.Reporter.Count(), 4; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	if len(tables) != 2 || !tables[0].Failed || !tables[1].Failed {
+		t.Errorf("both tables should fail")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"excel-tools/report"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// tableSeparator sheet名称中显示名称和表名的分隔符，例如 道具|Item 导出为 Item
+const tableSeparator = "|"
+
+// invalidFileChars 不能出现在导出文件名中的字符
+const invalidFileChars = `\/:*?"<>|`
+
+// TableName sheet导出的表名，依次使用merge中声明的表名、sheets中配置的table、sheet名称中|之后的部分、sheet名称，
+// 纵向键值表去掉开头的@
+func (c *Conf) TableName(file string, sheet string) string {
+	if table, ok := c.MergeTable(file, sheet); ok {
+		return table
+	}
+	if table := c.GetSheetConf(file, sheet).Table; table != "" {
+		return strings.TrimSpace(table)
+	}
+	name := strings.TrimPrefix(sheet, verticalSheetPrefix)
+	if i := strings.LastIndex(name, tableSeparator); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSpace(name)
+}
+
+// Source 表来自的sheet，格式为 工作簿文件名!sheet名称，合并导出的表列出所有sheet
+func (d *SheetData) Source() string {
+	if len(d.Parts) == 0 {
+		return filepath.Base(d.File) + "!" + d.Sheet
+	}
+	var sources []string
+	for _, part := range d.Parts {
+		sources = append(sources, part.Source())
+	}
+	return strings.Join(sources, ", ")
+}
+
+// CheckTableNames 校验表名能否作为导出的文件名，并且不同的表不会导出到同一个文件
+//
+// 文件名不区分大小写比较(Windows和macOS上 Item 和 item 是同一个文件)，enums为是否导出枚举定义，导出时enums也参与比较。存在冲突的表都不导出
+func CheckTableNames(tables []*SheetData, enums bool, reporter *report.Reporter) {
+	// 已占用的文件名以及导出该文件的表
+	outputs := make(map[string]*SheetData)
+	for _, data := range tables {
+		var err error
+		key := strings.ToLower(data.Name)
+		if first, ok := outputs[key]; ok {
+			err = fmt.Errorf("table %s has the same output path as table %s from %s", data.Name, first.Name, first.Source())
+			if !first.Failed {
+				reporter.Add(first.File, first.Sheet, fmt.Errorf("table %s has the same output path as table %s from %s", first.Name, data.Name, data.Source()))
+				first.Failed = true
+			}
+		} else {
+			switch {
+			case data.Name == "":
+				err = errors.New("table name is empty")
+			case strings.ContainsAny(data.Name, invalidFileChars):
+				err = fmt.Errorf("table name %q can not be used as a file name", data.Name)
+			case enums && key == strings.ToLower(enumFile):
+				err = fmt.Errorf("table %s has the same output path as the enum definitions file %s", data.Name, enumFile)
+			}
+			outputs[key] = data
+		}
+		if err != nil {
+			reporter.Add(data.File, data.Sheet, err)
+			data.Failed = true
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestTableName(t *testing.T) {
+	conf := testConf(t, `config:
+  merge: {Item: [Item_*]}
+  sheets: {道具: {table: Prop}, skill.xlsx!技能: {table: ' Skill '}}`)
+	tests := []struct {
+		file  string
+		sheet string
+		want  string
+	}{
+		{"dir/item.xlsx", "Item_weapon", "Item"},
+		{"dir/item.xlsx", "道具", "Prop"},
+		{"dir/skill.xlsx", "技能", "Skill"},
+		{"dir/item.xlsx", "道具|Equip", "Equip"},
+		{"dir/global.xlsx", "@全局|global", "global"},
+		{"dir/global.xlsx", "@const", "const"},
+		{"dir/hero.xlsx", "hero", "hero"},
+	}
+	for _, tt := range tests {
+		if got := conf.TableName(tt.file, tt.sheet); got != tt.want {
+			t.Errorf("TableName(%s, %s) = %s, want %s", tt.file, tt.sheet, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTableNames(t *testing.T) {
+	tables := func() []*SheetData {
+		return []*SheetData{
+			{File: "item.xlsx", Sheet: "道具|Item", Name: "Item"},
+			{File: "item.xlsx", Sheet: "item", Name: "item"},
+			{File: "hero.xlsx", Sheet: "hero", Name: "hero"},
+			{File: "hero.xlsx", Sheet: "a:b", Name: "a:b"},
+			{File: "enum.xlsx", Sheet: "enums", Name: "enums"},
+		}
+	}
+
+	parser := testParser(testConf(t, "config: {}"), nil)
+	sheets := tables()
+	CheckTableNames(sheets, true, parser.Reporter)
+	expectReported(t, parser.Reporter,
+		"table Item has the same output path as table item from item.xlsx!item",
+		"table item has the same output path as table Item from item.xlsx!道具|Item",
+		`table name "a:b" can not be used as a file name`,
+		"table enums has the same output path as the enum definitions file enums",
+	)
+	if got, want := parser.Reporter.Count(), 4; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	if sheets[2].Failed {
+		t.Errorf("table hero should not fail")
+	}
+
+	// 没有导出枚举定义时enums可以作为表名
+	parser = testParser(testConf(t, "config: {}"), nil)
+	sheets = tables()
+	CheckTableNames(sheets, false, parser.Reporter)
+	if got, want := parser.Reporter.Count(), 3; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	if sheets[4].Failed {
+		t.Errorf("table enums should not fail without enum definitions")
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"excel-tools/export"
+	"excel-tools/types"
+	"excel-tools/util"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pathSegment 字段路径中的一段，例如 skills[0] 的名称为skills、下标为0，不是数组元素时下标为-1
+type pathSegment struct {
+	name  string
+	index int
+}
+
+// segmentPattern 字段路径中的一段，例如 reward、skills[0]
+var segmentPattern = regexp.MustCompile(`^([^.\[\]]+)(?:\[(\d+)\])?$`)
+
+// indexPattern 字段路径中的下标
+var indexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// ParsePath 解析点号分隔的字段名，例如 reward.item、skills[0].id，不包含.和[]的字段名原样作为一段
+func ParsePath(name string) ([]pathSegment, error) {
+	if !strings.ContainsAny(name, ".[]") {
+		return []pathSegment{{name: name, index: -1}}, nil
+	}
+	var path []pathSegment
+	for _, part := range strings.Split(name, ".") {
+		match := segmentPattern.FindStringSubmatch(strings.TrimSpace(part))
+		if match == nil {
+			return nil, fmt.Errorf("invalid field name %q, expect a.b or a[0].b", name)
+		}
+		segment := pathSegment{name: match[1], index: -1}
+		if match[2] != "" {
+			segment.index, _ = strconv.Atoi(match[2])
+		}
+		path = append(path, segment)
+	}
+	return path, nil
+}
+
+// fieldNode 字段树的节点，叶子节点对应表格的一列，点号分隔的字段名组成对象，带下标的字段名组成数组
+type fieldNode struct {
+	name string
+	// 从根节点开始的路径，用于错误信息
+	path string
+	// 叶子节点对应的列，对象和数组为-1
+	col int
+	// 叶子节点对应的字段以及输出端
+	field  export.Field
+	client bool
+	server bool
+	// 数组元素的下标
+	index  int
+	object bool
+	array  bool
+	// 对象的字段按列顺序排列，数组的元素按下标排列
+	children []*fieldNode
+}
+
+// newFieldTree 创建字段树的根节点
+func newFieldTree() *fieldNode {
+	return &fieldNode{col: -1, index: -1, object: true}
+}
+
+// kind 节点的种类，用于错误信息
+func (n *fieldNode) kind() string {
+	switch {
+	case n.object:
+		return "object"
+	case n.array:
+		return "array"
+	default:
+		return "field"
+	}
+}
+
+// child 按名称查找对象的字段，不存在时创建
+func (n *fieldNode) child(name string) *fieldNode {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	path := name
+	if n.path != "" {
+		path = n.path + "." + name
+	}
+	child := &fieldNode{name: name, path: path, col: -1, index: -1}
+	n.children = append(n.children, child)
+	return child
+}
+
+// elem 按下标查找数组的元素，不存在时按下标顺序插入
+func (n *fieldNode) elem(index int) *fieldNode {
+	i := 0
+	for ; i < len(n.children) && n.children[i].index <= index; i++ {
+		if n.children[i].index == index {
+			return n.children[i]
+		}
+	}
+	elem := &fieldNode{name: n.name, path: fmt.Sprintf("%s[%d]", n.path, index), col: -1, index: index}
+	n.children = append(n.children[:i], append([]*fieldNode{elem}, n.children[i:]...)...)
+	return elem
+}
+
+// Add 将一列加入字段树，字段重复或者同一个名称既是值又是对象/数组时返回错误
+func (n *fieldNode) Add(field export.Field, path []pathSegment, col int, client bool, server bool) error {
+	name := field.Name
+	conflict := func(node *fieldNode) error {
+		return fmt.Errorf("field %q conflicts with %s %q", name, node.kind(), node.path)
+	}
+	node := n
+	for i, segment := range path {
+		node = node.child(segment.name)
+		if segment.index >= 0 {
+			if node.col >= 0 || node.object {
+				return conflict(node)
+			}
+			node.array = true
+			node = node.elem(segment.index)
+		}
+		if i == len(path)-1 {
+			if node.col >= 0 {
+				return fmt.Errorf("duplicate field %q", name)
+			}
+			if node.object || node.array {
+				return conflict(node)
+			}
+			node.col, node.client, node.server = col, client, server
+			node.field = field
+			node.field.Name = segment.name
+			return nil
+		}
+		if node.col >= 0 || node.array {
+			return conflict(node)
+		}
+		node.object = true
+	}
+	return nil
+}
+
+// nestedTypes 推导字段树中对象和数组的类型时生成的结构体，同名的结构体合并字段
+type nestedTypes struct {
+	structs []*types.Struct
+	// 已定义的枚举和自定义结构体，生成的结构体不能与之同名
+	defined *types.TypeFactory
+	// 所在的sheet，用于生成注释
+	sheet string
+}
+
+// register 注册生成的结构体，同名结构体(同一个数组的不同元素)的字段合并，同名字段的类型必须一致
+func (t *nestedTypes) register(s *types.Struct) error {
+	if t.defined.Structs[s.Name] != nil || t.defined.Enums[s.Name] != nil {
+		return fmt.Errorf("nested type %s has the same name as a defined type", s.Name)
+	}
+	for _, exists := range t.structs {
+		if exists.Name != s.Name {
+			continue
+		}
+		for _, field := range s.Fields {
+			if f, ok := exists.Field(field.Name); !ok {
+				exists.Fields = append(exists.Fields, field)
+			} else if f.Type != field.Type {
+				return fmt.Errorf("field %s of %s has different types %s and %s", field.Name, s.Name, f.Type, field.Type)
+			} else {
+				f.Nullable = f.Nullable || field.Nullable
+			}
+		}
+		return nil
+	}
+	t.structs = append(t.structs, s)
+	return nil
+}
+
+// form 推导节点的类型，叶子节点为列的类型，对象为生成的结构体，数组为元素类型加[]
+func (t *nestedTypes) form(n *fieldNode, prefix string) (string, error) {
+	switch {
+	case n.object:
+		s := &types.Struct{
+			Name:    prefix + util.CamelCase(n.name),
+			Comment: fmt.Sprintf("%s 表的 %s 字段", t.sheet, indexPattern.ReplaceAllString(n.path, "")),
+			Nested:  true,
+		}
+		for _, child := range n.children {
+			form, err := t.form(child, s.Name)
+			if err != nil {
+				return "", err
+			}
+			s.Fields = append(s.Fields, &types.StructField{Name: child.name, Type: form, Nullable: child.field.Nullable})
+		}
+		return s.Name, t.register(s)
+	case n.array:
+		elem := ""
+		for i, child := range n.children {
+			// 元素按下标顺序输出，下标不连续时导出的数组会错位
+			if child.index != i {
+				return "", fmt.Errorf("array %s has no element %s[%d], indexes must start from 0 without gaps", n.path, n.path, i)
+			}
+			form, err := t.form(child, prefix)
+			if err != nil {
+				return "", err
+			}
+			if elem != "" && form != elem {
+				return "", fmt.Errorf("array %s has elements of different types %s and %s", n.path, elem, form)
+			}
+			elem = form
+		}
+		return elem + "[]", nil
+	default:
+		return n.field.Type, nil
+	}
+}
+
+// Fields 推导根节点下每个字段的类型，返回客户端、服务端字段以及生成的结构体，对象和数组只要有一列输出即输出
+func (n *fieldNode) Fields(sheet string, typeFactory *types.TypeFactory) (client []export.Field, server []export.Field, structs []*types.Struct, err error) {
+	t := &nestedTypes{defined: typeFactory, sheet: sheet}
+	for _, child := range n.children {
+		form, err := t.form(child, util.CamelCase(sheet))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		field := export.Field{Name: child.name, Type: form}
+		if child.col >= 0 {
+			field = child.field
+		}
+		toClient, toServer := child.sides()
+		if toClient {
+			client = append(client, field)
+		}
+		if toServer {
+			server = append(server, field)
+		}
+	}
+	return client, server, t.structs, nil
+}
+
+// sides 节点是否输出到客户端/服务端
+func (n *fieldNode) sides() (client bool, server bool) {
+	if n.col >= 0 {
+		return n.client, n.server
+	}
+	for _, child := range n.children {
+		c, s := child.sides()
+		client, server = client || c, server || s
+	}
+	return client, server
+}
+
+// Value 按字段树组装节点输出到客户端或者服务端的值，values为每列转换后的值，没有任何值时返回false
+func (n *fieldNode) Value(values map[int]interface{}, server bool) (interface{}, bool) {
+	switch {
+	case n.object:
+		object := make(map[string]interface{})
+		for _, child := range n.children {
+			if v, ok := child.Value(values, server); ok {
+				object[child.name] = v
+			}
+		}
+		return object, len(object) > 0
+	case n.array:
+		var array []interface{}
+		for _, child := range n.children {
+			if v, ok := child.Value(values, server); ok {
+				array = append(array, v)
+			}
+		}
+		return array, len(array) > 0
+	default:
+		v, ok := values[n.col]
+		if server {
+			return v, ok && n.server
+		}
+		return v, ok && n.client
+	}
+}
+
+// has 节点是否有任意一列存在转换后的值
+func (n *fieldNode) has(values map[int]interface{}) bool {
+	if n.col >= 0 {
+		_, ok := values[n.col]
+		return ok
+	}
+	for _, child := range n.children {
+		if child.has(values) {
+			return true
+		}
+	}
+	return false
+}
+
+// first 节点中的第一个叶子节点
+func (n *fieldNode) first() *fieldNode {
+	if n.col >= 0 || len(n.children) == 0 {
+		return n
+	}
+	return n.children[0].first()
+}
+
+// Gap 查找之后还有元素的空数组元素，空元素不输出，之后的元素会错位。返回空元素的路径以及其中的第一个叶子节点，用于定位单元格
+func (n *fieldNode) Gap(values map[int]interface{}) (string, *fieldNode, bool) {
+	var empty *fieldNode
+	for _, child := range n.children {
+		if !child.has(values) {
+			if n.array && empty == nil {
+				empty = child
+			}
+			continue
+		}
+		if empty != nil {
+			return empty.path, empty.first(), true
+		}
+		if path, leaf, ok := child.Gap(values); ok {
+			return path, leaf, true
+		}
+	}
+	return "", nil, false
+}
+
+// Rows 按字段树组装一条记录输出到客户端和服务端的数据，values为每列转换后的值
+func (n *fieldNode) Rows(values map[int]interface{}) (client map[string]interface{}, server map[string]interface{}) {
+	client = make(map[string]interface{})
+	server = make(map[string]interface{})
+	for _, node := range n.children {
+		if value, ok := node.Value(values, false); ok {
+			client[node.name] = value
+		}
+		if value, ok := node.Value(values, true); ok {
+			server[node.name] = value
+		}
+	}
+	return client, server
+}
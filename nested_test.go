@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func TestParseNested(t *testing.T) {
+	path := writeWorkbook(t, "hero.xlsx", testSheet{"hero", [][]interface{}{
+		{"编号", "奖励道具", "奖励数量", "技能", "等级", "技能", "等级", "标签", "标签"},
+		{"*id", "reward.item", "reward.count", "skills[0].id", "skills[0].lv", "skills[1].id", "skills[1].lv", "tags[0]", "tags[1]"},
+		{"int", "int", "int", "int", "int", "int", "int", "string", "string"},
+		{"cs", "cs", "cs", "cs", "cs", "cs", "s", "cs", "cs"},
+		{"1", "1001", "5", "11", "1", "12", "2", "a", "b"},
+		// 空单元格省略，所有单元格都为空的元素以及没有值的对象不输出
+		{"2", "", "", "11", "", "", "", "a", ""},
+	}})
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	data := parseWorkbook(t, parser, path)[0]
+	expectNoErrors(t, parser.Reporter)
+
+	want := `[{"id":1,"reward":{"count":5,"item":1001},"skills":[{"id":11,"lv":1},{"id":12,"lv":2}],"tags":["a","b"]},` +
+		`{"id":2,"skills":[{"id":11}],"tags":["a"]}]`
+	if got := exportJson(t, data); got != want {
+		t.Errorf("hero = %s, want %s", got, want)
+	}
+	// 对象和数组元素生成以表名和路径命名的类型
+	var names []string
+	for _, s := range data.Structs {
+		names = append(names, s.Name)
+	}
+	if got, want := len(names), 2; got != want || names[0] != "HeroReward" || names[1] != "HeroSkills" {
+		t.Errorf("structs = %v, want [HeroReward HeroSkills]", names)
+	}
+}
+
+func TestParseNestedErrors(t *testing.T) {
+	path := writeWorkbook(t, "hero.xlsx",
+		testSheet{"gap", [][]interface{}{
+			{"编号", "技能", "等级", "技能", "等级", "标签", "标签", "标签"},
+			{"*id", "skills[0].id", "skills[0].lv", "skills[1].id", "skills[1].lv", "tags[0]", "tags[1]", "tags[2]"},
+			{"int", "int", "int", "int", "int", "string", "string", "string"},
+			{"cs", "cs", "cs", "cs", "cs", "cs", "cs", "cs"},
+			{"1", "11", "1", "12", "2", "a", "b", "c"},
+			{"2", "", "", "12", "2", "a", "", ""},
+			{"3", "11", "1", "", "", "a", "", "c"},
+		}},
+		testSheet{"index", [][]interface{}{
+			{"编号", "标签", "标签"},
+			{"id", "tags[1]", "tags[2]"},
+			{"int", "string", "string"},
+			{"cs", "cs", "cs"},
+		}},
+		testSheet{"conflict", [][]interface{}{
+			{"编号", "奖励", "奖励道具", "标签", "标签"},
+			{"id", "reward", "reward.item", "tags[0]", "tags[0]"},
+			{"int", "int", "int", "string", "string"},
+			{"cs", "cs", "cs", "cs", "cs"},
+		}},
+		testSheet{"primary", [][]interface{}{
+			{"编号"},
+			{"*reward.id"},
+			{"int"},
+			{"cs"},
+		}},
+	)
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectReported(t, parser.Reporter,
+		`hero.xlsx!gap!B6 (field "skills[0].id", type int): array element skills[0] is empty but a later element is filled`,
+		`hero.xlsx!gap!G7 (field "tags[1]", type string): array element tags[1] is empty but a later element is filled`,
+		"array tags has no element tags[0], indexes must start from 0 without gaps",
+		`field "reward.item" conflicts with field "reward"`,
+		`duplicate field "tags[0]"`,
+		`primary key "reward.id" must be a top-level field`,
+	)
+	if got, want := parser.Reporter.Count(), 6; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	if sheets[0] == nil || !sheets[0].Failed || len(sheets[0].Servers) != 1 {
+		t.Errorf("gap sheet should fail and keep only the first row")
+	}
+	for i, data := range sheets[1:] {
+		if data != nil {
+			t.Errorf("sheet %d parsed, want a header error", i+1)
+		}
+	}
+}
+
+func TestParseVerticalNestedGap(t *testing.T) {
+	path := writeWorkbook(t, "global.xlsx", testSheet{"@global", [][]interface{}{
+		{"name", "type", "value", "out", "comment"},
+		{"tags[0]", "string", "", "", ""},
+		{"tags[1]", "string", "b", "", ""},
+	}})
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	data := parseWorkbook(t, parser, path)[0]
+	expectReported(t, parser.Reporter,
+		`global.xlsx!@global!C2 (field "tags[0]", type string): array element tags[0] is empty but a later element is filled`,
+	)
+	if data == nil || !data.Failed {
+		t.Errorf("vertical sheet should fail")
+	}
+}
@@ -0,0 +1,122 @@
+package report
+
+import (
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"path/filepath"
+)
+
+// CellError 单元格错误，携带工作簿、sheet、单元格坐标以及字段信息，便于配表人员定位
+type CellError struct {
+	// 工作簿文件
+	File string
+	// sheet名称
+	Sheet string
+	// 列索引，从0开始
+	Col int
+	// 行索引，从0开始
+	Row int
+	// 字段名
+	Field string
+	// 字段类型
+	Type string
+	// 原始错误
+	Err error
+}
+
+// Axis 单元格坐标，例如 D17
+func (e *CellError) Axis() string {
+	return Axis(e.Col, e.Row)
+}
+
+// Axis 将从0开始的列索引和行索引转换为单元格坐标
+func Axis(col int, row int) string {
+	axis, err := excelize.CoordinatesToCellName(col+1, row+1)
+	if err != nil {
+		return fmt.Sprintf("R%dC%d", row+1, col+1)
+	}
+	return axis
+}
+
+// Error 格式：item.xlsx!Item!D17 (field "price", type int): "12a" is not an integer
+func (e *CellError) Error() string {
+	return fmt.Sprintf("%s!%s!%s (field %q, type %s): %v", filepath.Base(e.File), e.Sheet, e.Axis(), e.Field, e.Type, e.Err)
+}
+
+// Unwrap 返回原始错误
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// Reporter 收集一次导出过程中的所有错误，结束时按工作簿/sheet分组输出
+type Reporter struct {
+	groups []*group
+}
+
+// group 同一个工作簿/sheet下的错误
+type group struct {
+	file  string
+	sheet string
+	errs  []error
+}
+
+// Add 记录错误，sheet为空表示工作簿级别的错误
+func (r *Reporter) Add(file string, sheet string, err error) {
+	for _, g := range r.groups {
+		if g.file == file && g.sheet == sheet {
+			g.errs = append(g.errs, err)
+			return
+		}
+	}
+	r.groups = append(r.groups, &group{file: file, sheet: sheet, errs: []error{err}})
+}
+
+// Count 错误总数
+func (r *Reporter) Count() int {
+	count := 0
+	for _, g := range r.groups {
+		count += len(g.errs)
+	}
+	return count
+}
+
+// HasErrors 是否存在错误
+func (r *Reporter) HasErrors() bool {
+	return len(r.groups) > 0
+}
+
+// Print 输出分组错误报告
+func (r *Reporter) Print(w io.Writer) {
+	if !r.HasErrors() {
+		return
+	}
+	fmt.Fprintf(w, "\r\n%d error(s) found:\r\n", r.Count())
+	// 同一个工作簿的错误输出在一起，工作簿按首次出现的顺序输出
+	var files []string
+	byFile := make(map[string][]*group)
+	for _, g := range r.groups {
+		if _, ok := byFile[g.file]; !ok {
+			files = append(files, g.file)
+		}
+		byFile[g.file] = append(byFile[g.file], g)
+	}
+	for _, file := range files {
+		fmt.Fprintf(w, "[%s]\r\n", filepath.Base(file))
+		for _, g := range byFile[file] {
+			g.print(w)
+		}
+	}
+}
+
+// print 输出该组的错误
+func (g *group) print(w io.Writer) {
+	indent := "  "
+	if g.sheet != "" {
+		fmt.Fprintf(w, "  sheet %s: %d error(s)\r\n", g.sheet, len(g.errs))
+		indent = "    "
+	}
+	for _, err := range g.errs {
+		fmt.Fprintf(w, "%s%v\r\n", indent, err)
+	}
+}
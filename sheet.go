@@ -0,0 +1,605 @@
+package main
+
+import (
+	"errors"
+	"excel-tools/export"
+	"excel-tools/report"
+	"excel-tools/types"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"strings"
+)
+
+// MergeCell 合并单元格
+type MergeCell struct {
+	col   int
+	row   int
+	value string
+}
+
+// SheetData 解析完成的sheet，所有工作簿加载完成后再统一校验引用并导出
+type SheetData struct {
+	// 工作簿文件
+	File string
+	// sheet名称，用于错误信息
+	Sheet string
+	// 表名，同时也是导出数据的文件名，一般和sheet名称相同
+	Name string
+	// 导出选项
+	Options export.Options
+	// 客户端/服务器字段，按列顺序
+	ClientFields []export.Field
+	ServerFields []export.Field
+	// 客户端/服务器数据
+	Clients []map[string]interface{}
+	Servers []map[string]interface{}
+	// 每个字段出现过的值，用于校验其它表对该表的引用
+	Columns map[string]map[string]bool
+	// 需要校验的引用单元格
+	Refs []*RefCell
+	// 主键单元格，按行顺序
+	Keys []*KeyCell
+	// 合并导出时组成该表的sheet，没有合并时为空
+	Parts []*SheetData
+	// 点号分隔的字段名组成的对象对应的结构体，例如 reward.item 生成 <Sheet>Reward
+	Structs []*types.Struct
+	// 是否存在错误，存在错误的sheet不导出
+	Failed bool
+}
+
+// RefCell 引用类型的单元格
+type RefCell struct {
+	Col int
+	Row int
+	// 类型所在的单元格，引用的表或字段不存在时报告
+	TypeCol int
+	TypeRow int
+	Field   string
+	Type    string
+	Value   interface{}
+}
+
+// OutSides 解析列的输出端，包含cs或者sc表示客户端和服务端都会输出
+func OutSides(out string) (client bool, server bool) {
+	if strings.Contains(out, "cs") || strings.Contains(out, "sc") {
+		return true, true
+	}
+	return strings.Contains(out, "c"), strings.Contains(out, "s")
+}
+
+// ParseName 解析字段名，以*开头表示该列为主键
+func ParseName(raw string) (name string, primary bool) {
+	name = strings.TrimSpace(raw)
+	if strings.HasPrefix(name, "*") {
+		return strings.TrimSpace(name[1:]), true
+	}
+	return name, false
+}
+
+// FindField 按字段名查找字段
+func FindField(fields []export.Field, name string) (export.Field, bool) {
+	for _, field := range fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return export.Field{}, false
+}
+
+// CheckKeyField 以主键作为键导出时，主键必须是整数或字符串，并且导出到每一个有字段的输出端
+func CheckKeyField(key string, clientFields []export.Field, serverFields []export.Field) error {
+	_, inClient := FindField(clientFields, key)
+	_, inServer := FindField(serverFields, key)
+	if key == "" || !inClient && !inServer {
+		return fmt.Errorf("keyed export requires a primary key column, mark it with * in the name row")
+	}
+	sides := []struct {
+		name   string
+		fields []export.Field
+	}{
+		{"client", clientFields},
+		{"server", serverFields},
+	}
+	for _, side := range sides {
+		if len(side.fields) == 0 {
+			continue
+		}
+		field, ok := FindField(side.fields, key)
+		if !ok {
+			return fmt.Errorf("primary key %q is not exported to %s", key, side.name)
+		}
+		if field.Type != "int" && field.Type != "long" && field.Type != "string" {
+			return fmt.Errorf("primary key %q has type %s, expect int, long or string", key, field.Type)
+		}
+	}
+	return nil
+}
+
+// SheetParser sheet解析器，持有解析所有sheet共用的配置
+type SheetParser struct {
+	Conf    *Conf
+	Options *export.Options
+	// 类型工厂，包含所有枚举定义
+	Types    *types.TypeFactory
+	Reporter *report.Reporter
+}
+
+// Column 解析后的字段，对应横向sheet的一列或者纵向sheet的一行
+type Column struct {
+	Field export.Field
+	// 字段名以*开头表示主键
+	Primary bool
+	// 空单元格使用的默认值，例如类型为 int=0
+	Default    string
+	HasDefault bool
+	// 是否为数组元素，例如 skills[0]，空单元格不输出
+	Element bool
+}
+
+// HeaderError 表头单元格的错误，Role为单元格在表头中的作用，例如 name、type
+type HeaderError struct {
+	Role string
+	Err  error
+}
+
+// ParseColumn 解析字段的表头，header按作用返回表头单元格，字段名、类型、默认值、约束的错误按所在的单元格返回
+func (p *SheetParser) ParseColumn(header func(role string) string) (*Column, []HeaderError) {
+	typeFactory := p.Types
+	var errs []HeaderError
+	cellError := func(role string, err error) {
+		errs = append(errs, HeaderError{Role: role, Err: err})
+	}
+
+	name, primary := ParseName(header("name"))
+	form, def, hasDefault := types.ParseDefault(header("type"))
+	form, marker := types.ParseMarker(form)
+	form, constraint, hasConstraint := types.ParseConstraint(form)
+	column := &Column{
+		Field: export.Field{
+			Name:     name,
+			Type:     form,
+			Note:     header("note"),
+			Nullable: marker == '?',
+			Required: marker == '!',
+		},
+		Primary: primary,
+	}
+	field := &column.Field
+	// 默认值和约束可以写在类型中，也可以写在单独的表头行中
+	defaultRole, constraintRole := "type", "type"
+	if text := header("default"); text != "" {
+		if hasDefault {
+			cellError("default", errors.New("default value is set in both the type row and the default row"))
+		}
+		def, hasDefault, defaultRole = text, true, "default"
+	}
+	if text := header("constraint"); text != "" {
+		if hasConstraint {
+			cellError("constraint", errors.New("constraint is set in both the type row and the constraint row"))
+		}
+		constraint, hasConstraint, constraintRole = text, true, "constraint"
+	}
+	if name == "" {
+		cellError("name", errors.New("field name is empty"))
+	}
+	if err := typeFactory.Check(field.Type); err != nil {
+		cellError("type", err)
+	} else if hasDefault && marker != 0 {
+		cellError(defaultRole, fmt.Errorf("type %s%c can not have a default value", form, marker))
+	} else {
+		if hasConstraint {
+			if field.Constraint, err = typeFactory.GetConstraint(field.Type, constraint); err != nil {
+				cellError(constraintRole, err)
+			}
+		}
+		if hasDefault {
+			// 默认值在解析表头时按字段的类型转换并校验一次，提前发现错误
+			field.Default, err = typeFactory.GetConvert(field.Type).Handle(def)
+			if err == nil && field.Constraint != nil {
+				err = field.Constraint.Check(field.Default)
+			}
+			if err != nil {
+				cellError(defaultRole, fmt.Errorf("default value: %w", err))
+			}
+			column.Default, column.HasDefault = def, true
+		}
+	}
+	return column, errs
+}
+
+// Convert 按字段的类型转换单元格并校验取值约束，空单元格使用默认值，int! 不能为空，int? 为空时返回nil。
+// 单元格不需要输出时返回false
+func (p *SheetParser) Convert(column *Column, value string) (interface{}, bool, error) {
+	field := column.Field
+	if column.HasDefault && value == "" {
+		value = column.Default
+	}
+	if strings.TrimSpace(value) == "" {
+		if field.Required {
+			return nil, false, errors.New("value is required")
+		}
+		if field.Nullable {
+			return nil, true, nil
+		}
+		// 数组元素的空单元格不输出，避免数组中出现空值
+		if column.Element {
+			return nil, false, nil
+		}
+	}
+	if value == "" && field.Type != "string" && field.Type != "array" && field.Type != "object" {
+		return nil, false, nil
+	}
+	converted, err := p.Types.GetConvert(field.Type).Handle(value)
+	if err != nil {
+		return nil, false, err
+	}
+	// 校验取值约束，例如 int(1..100)
+	if field.Constraint != nil {
+		if err := field.Constraint.Check(converted); err != nil {
+			return nil, false, err
+		}
+	}
+	return converted, true, nil
+}
+
+// AddColumn 将字段加入字段树，index为字段的值所在的列(横向sheet)或者行(纵向sheet)，out为输出端。
+// 主键必须是顶层字段，纵向sheet不支持主键，错误都属于字段名所在的单元格
+func (p *SheetParser) AddColumn(tree *fieldNode, column *Column, index int, out string, vertical bool) []HeaderError {
+	var errs []HeaderError
+	name := column.Field.Name
+	path, err := ParsePath(name)
+	if err == nil {
+		toClient, toServer := OutSides(out)
+		err = tree.Add(column.Field, path, index, toClient, toServer)
+	}
+	if err != nil {
+		errs = append(errs, HeaderError{Role: "name", Err: err})
+	}
+	for _, segment := range path {
+		column.Element = column.Element || segment.index >= 0
+	}
+	if column.Primary {
+		if vertical {
+			errs = append(errs, HeaderError{Role: "name", Err: fmt.Errorf("primary key %q is not supported in a vertical sheet", name)})
+		} else if len(path) > 1 {
+			errs = append(errs, HeaderError{Role: "name", Err: fmt.Errorf("primary key %q must be a top-level field", name)})
+		}
+	}
+	return errs
+}
+
+// BuildFields 按字段树生成客户端/服务端字段，点号分隔的字段名生成的结构体加入导出选项
+func (p *SheetParser) BuildFields(data *SheetData, tree *fieldNode) error {
+	var err error
+	data.ClientFields, data.ServerFields, data.Structs, err = tree.Fields(data.Name, p.Types)
+	if err != nil {
+		return err
+	}
+	data.Options = *p.Options
+	if len(data.Structs) > 0 {
+		data.Options.Structs = append(append([]*types.Struct(nil), p.Options.Structs...), data.Structs...)
+	}
+	return nil
+}
+
+// Record 记录字段出现过的值，引用类型的字段同时记录引用单元格，用于所有sheet解析完成后校验引用。
+// (col, row)为值所在的单元格，(typeCol, typeRow)为类型所在的单元格
+func (d *SheetData) Record(field export.Field, value interface{}, col int, row int, typeCol int, typeRow int) {
+	if values, ok := d.Columns[field.Name]; ok {
+		values[fmt.Sprint(value)] = true
+	}
+	if _, _, _, ok := types.ParseRef(field.Type); ok {
+		d.Refs = append(d.Refs, &RefCell{
+			Col: col, Row: row, TypeCol: typeCol, TypeRow: typeRow,
+			Field: field.Name, Type: field.Type, Value: value,
+		})
+	}
+}
+
+// Parse 解析sheet的表头和数据，单元格错误记录到Reporter并标记该sheet失败，表头错误时返回nil
+func (p *SheetParser) Parse(f *excelize.File, file string, sheet string) *SheetData {
+	conf, reporter := p.Conf, p.Reporter
+	if conf.IsVerticalSheet(file, sheet) {
+		return p.ParseVertical(f, file, sheet)
+	}
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		fmt.Printf("read sheet %s failed\r\n", sheet)
+		reporter.Add(file, sheet, err)
+		return nil
+	}
+	layout, err := conf.GetHeader(file, sheet).Layout()
+	if err != nil {
+		reporter.Add(file, sheet, err)
+		return nil
+	}
+	if len(rows) < layout.Size {
+		reporter.Add(file, sheet, fmt.Errorf("sheet %s has %d row(s), expect at least %d header rows", sheet, len(rows), layout.Size))
+		return nil
+	}
+	// 表头单元格，按配置的作用读取，表头各行的长度可能不一致，超出的单元格为空
+	header := func(role string, col int) string {
+		row := layout.Row(role)
+		if row < 0 || col >= len(rows[row]) {
+			return ""
+		}
+		return strings.TrimSpace(rows[row][col])
+	}
+	// 表头的列数
+	width := 0
+	for _, row := range rows[:layout.Size] {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	// 合并单元格
+	cells, _ := f.GetMergeCells(sheet)
+	// 合并单元格值
+	var mergeValues []MergeCell
+
+	if len(cells) > 0 {
+		for _, cell := range cells {
+			startCol, startRow, _ := excelize.CellNameToCoordinates(cell.GetStartAxis())
+			endCol, endRow, _ := excelize.CellNameToCoordinates(cell.GetEndAxis())
+			for j := startRow - 1; j <= endRow-1; j++ {
+				for i := startCol - 1; i <= endCol-1; i++ {
+					mergeValues = append(mergeValues, MergeCell{
+						i, j, cell.GetCellValue(),
+					})
+				}
+			}
+		}
+	}
+
+	data := &SheetData{
+		File:    file,
+		Sheet:   sheet,
+		Name:    conf.TableName(file, sheet),
+		Columns: make(map[string]map[string]bool),
+	}
+	// 主键字段以及主键所在的列
+	var primaryKeys []string
+	keyCol := -1
+	// 表头是否存在错误
+	invalid := false
+	// 字段树，点号分隔的字段名组成嵌套的对象，带下标的字段名组成数组
+	tree := newFieldTree()
+	// 每列解析后的字段
+	columns := make(map[int]*Column)
+	for colIndex := 0; colIndex < width; colIndex++ {
+		// 注释或者字段名以#开头表示忽略该列，字段名和类型都为空的列也忽略
+		if strings.HasPrefix(header("note", colIndex), "#") || strings.HasPrefix(header("name", colIndex), "#") {
+			continue
+		}
+		if header("name", colIndex) == "" && header("type", colIndex) == "" {
+			continue
+		}
+		column, errs := p.ParseColumn(func(role string) string {
+			return header(role, colIndex)
+		})
+		name := column.Field.Name
+		cellError := func(role string, err error) {
+			reporter.Add(file, sheet, &report.CellError{
+				File: file, Sheet: sheet, Col: colIndex, Row: layout.Row(role),
+				Field: name, Type: column.Field.Type, Err: err,
+			})
+			invalid = true
+		}
+		for _, e := range errs {
+			cellError(e.Role, e.Err)
+		}
+		columns[colIndex] = column
+		// 没有输出端行时客户端和服务端都输出
+		out := header("out", colIndex)
+		if layout.Row("out") < 0 {
+			out = "cs"
+		}
+		for _, e := range p.AddColumn(tree, column, colIndex, out, false) {
+			cellError(e.Role, e.Err)
+		}
+		if column.Primary {
+			primaryKeys = append(primaryKeys, name)
+			keyCol = colIndex
+		}
+		data.Columns[name] = make(map[string]bool)
+	}
+
+	if invalid {
+		return nil
+	}
+	if err := p.BuildFields(data, tree); err != nil {
+		reporter.Add(file, sheet, err)
+		return nil
+	}
+
+	// 以主键作为键导出时检查主键列
+	data.Options.Keyed = conf.Config.Output.Keyed
+	if sc := conf.GetSheetConf(file, sheet); sc.Keyed != nil {
+		data.Options.Keyed = *sc.Keyed
+	}
+	if len(primaryKeys) > 1 {
+		reporter.Add(file, sheet, fmt.Errorf("multiple primary key columns: %s", strings.Join(primaryKeys, ", ")))
+		return nil
+	} else if len(primaryKeys) == 1 {
+		data.Options.Key = primaryKeys[0]
+	} else {
+		// 没有标记主键时使用默认主键字段所在的列
+		for colIndex := 0; colIndex < width; colIndex++ {
+			if column, ok := columns[colIndex]; ok && column.Field.Name == data.Options.Key {
+				keyCol = colIndex
+				break
+			}
+		}
+	}
+	if data.Options.Keyed {
+		if err := CheckKeyField(data.Options.Key, data.ClientFields, data.ServerFields); err != nil {
+			reporter.Add(file, sheet, err)
+			return nil
+		}
+	}
+
+	// 已出现的主键以及所在的单元格
+	keys := make(map[string]string)
+
+	for rowIndex, row := range rows {
+		if rowIndex < layout.Data {
+			continue
+		}
+		// 每列转换后的值
+		converted := make(map[int]interface{})
+
+		for colIndex := 0; colIndex < width; colIndex++ {
+			// 忽略的列
+			column, ok := columns[colIndex]
+			if !ok {
+				continue
+			}
+			// 数据行可能比表头短，超出的单元格为空
+			value := ""
+			if colIndex < len(row) {
+				value = row[colIndex]
+			}
+
+			if value == "" {
+				for _, cell := range mergeValues {
+					if cell.col == colIndex && cell.row == rowIndex {
+						value = cell.value
+						break
+					}
+				}
+			}
+
+			name, form := column.Field.Name, column.Field.Type
+			cellError := func(err error) {
+				reporter.Add(file, sheet, &report.CellError{
+					File: file, Sheet: sheet, Col: colIndex, Row: rowIndex,
+					Field: name, Type: form, Err: err,
+				})
+				data.Failed = true
+			}
+
+			// 主键不能为空
+			if colIndex == keyCol && strings.TrimSpace(value) == "" {
+				cellError(errors.New("primary key is empty"))
+				continue
+			}
+
+			// 类型转换并校验取值约束，空单元格按默认值以及 int?/int! 处理
+			v, ok, err := p.Convert(column, value)
+			if err != nil {
+				cellError(err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			converted[colIndex] = v
+			// int? 的空单元格导出为null，不记录字段的值
+			if v == nil {
+				continue
+			}
+
+			// 主键不能重复，按转换后的值比较
+			if colIndex == keyCol {
+				key := fmt.Sprint(v)
+				if first, ok := keys[key]; ok {
+					cellError(fmt.Errorf("duplicate primary key %s, first defined at %s", key, first))
+				} else {
+					keys[key] = report.Axis(colIndex, rowIndex)
+					data.Keys = append(data.Keys, &KeyCell{Col: colIndex, Row: rowIndex, Value: key})
+				}
+			}
+
+			// 记录字段的值以及引用单元格
+			data.Record(column.Field, v, colIndex, rowIndex, colIndex, layout.Row("type"))
+		}
+
+		// 数组中间的空元素会导致之后的元素错位
+		if path, leaf, ok := tree.Gap(converted); ok {
+			reporter.Add(file, sheet, &report.CellError{
+				File: file, Sheet: sheet, Col: leaf.col, Row: rowIndex,
+				Field: leaf.path, Type: leaf.field.Type, Err: fmt.Errorf("array element %s is empty but a later element is filled", path),
+			})
+			data.Failed = true
+			continue
+		}
+
+		// 按字段树组装客户端/服务端数据
+		client, server := tree.Rows(converted)
+		if len(client) > 0 {
+			data.Clients = append(data.Clients, client)
+		}
+		if len(server) > 0 {
+			data.Servers = append(data.Servers, server)
+		}
+	}
+	return data
+}
+
+// CheckRefs 校验所有引用是否指向存在的记录，合并导出的表使用所有sheet中的记录
+//
+// 引用的表或字段不存在时每列只报告一次，引用的值不存在时报告每一个单元格，存在错误的sheet标记为失败
+func CheckRefs(sheets []*SheetData, reporter *report.Reporter) {
+	// 每个表所有字段出现过的值
+	tables := make(map[string]map[string]map[string]bool)
+	for _, data := range sheets {
+		if tables[data.Name] == nil {
+			tables[data.Name] = make(map[string]map[string]bool)
+		}
+		for field, values := range data.Columns {
+			if tables[data.Name][field] == nil {
+				tables[data.Name][field] = make(map[string]bool)
+			}
+			for value := range values {
+				tables[data.Name][field][value] = true
+			}
+		}
+	}
+	for _, data := range sheets {
+		// 已经报告过的类型单元格，纵向sheet每一行的类型都在同一列，按单元格而不是按列去重
+		type cell struct{ col, row int }
+		reported := make(map[cell]bool)
+		for _, ref := range data.Refs {
+			if reported[cell{ref.TypeCol, ref.TypeRow}] {
+				continue
+			}
+			table, field, _, _ := types.ParseRef(ref.Type)
+			cellError := &report.CellError{
+				File: data.File, Sheet: data.Sheet, Col: ref.Col, Row: ref.Row,
+				Field: ref.Field, Type: ref.Type,
+			}
+			columns, ok := tables[table]
+			if !ok {
+				cellError.Err = fmt.Errorf("referenced table %q not found", table)
+			} else if _, ok := columns[field]; !ok {
+				cellError.Err = fmt.Errorf("referenced field %q not found in table %s", field, table)
+			}
+			if cellError.Err != nil {
+				// 表头错误，定位到类型所在的单元格
+				cellError.Col, cellError.Row = ref.TypeCol, ref.TypeRow
+				reported[cell{ref.TypeCol, ref.TypeRow}] = true
+				reporter.Add(data.File, data.Sheet, cellError)
+				data.Failed = true
+				continue
+			}
+			column := columns[field]
+			var errs []error
+			if values, ok := ref.Value.([]interface{}); ok {
+				for i, value := range values {
+					if !column[fmt.Sprint(value)] {
+						errs = append(errs, fmt.Errorf("element %d: %s.%s %v does not exist", i, table, field, value))
+					}
+				}
+			} else if !column[fmt.Sprint(ref.Value)] {
+				errs = append(errs, fmt.Errorf("%s.%s %v does not exist", table, field, ref.Value))
+			}
+			for _, err := range errs {
+				e := *cellError
+				e.Err = err
+				reporter.Add(data.File, data.Sheet, &e)
+				data.Failed = true
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestParsePrimaryKey(t *testing.T) {
+	path := writeWorkbook(t, "item.xlsx",
+		testSheet{"item", [][]interface{}{
+			{"编号", "名称"},
+			{"*id", "name"},
+			{"int", "string"},
+			{"cs", "cs"},
+			{"1", "木剑"},
+			{"", "铁剑"},
+			{"01", "铜剑"},
+			{"2", "银剑"},
+		}},
+		// 没有标记主键时校验默认主键字段
+		testSheet{"shop", [][]interface{}{
+			{"编号", "名称"},
+			{"name", "id"},
+			{"string", "string"},
+			{"cs", "cs"},
+			{"a", "x"},
+			{"a", "x"},
+		}},
+		// 没有主键字段时不校验
+		testSheet{"drop", [][]interface{}{
+			{"名称"},
+			{"name"},
+			{"string"},
+			{"cs"},
+			{"a"},
+			{"a"},
+		}},
+	)
+	parser := testParser(testConf(t, "config: {output: {format: json, key: id}}"), nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectReported(t, parser.Reporter,
+		`item.xlsx!item!A6 (field "id", type int): primary key is empty`,
+		`item.xlsx!item!A7 (field "id", type int): duplicate primary key 1, first defined at A5`,
+		`item.xlsx!shop!B6 (field "id", type string): duplicate primary key x, first defined at B5`,
+	)
+	if parser.Reporter.Count() != 3 {
+		t.Errorf("reported %d error(s), want 3:\n%s", parser.Reporter.Count(), reported(parser.Reporter))
+	}
+	if !sheets[0].Failed || !sheets[1].Failed || sheets[2].Failed {
+		t.Errorf("failed = %v, %v, %v, want true, true, false", sheets[0].Failed, sheets[1].Failed, sheets[2].Failed)
+	}
+}
+
+func TestParseMultiplePrimaryKeys(t *testing.T) {
+	path := writeWorkbook(t, "item.xlsx", testSheet{"item", [][]interface{}{
+		{"编号", "名称"},
+		{"*id", "*name"},
+		{"int", "string"},
+		{"cs", "cs"},
+	}})
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	if data := parseWorkbook(t, parser, path)[0]; data != nil {
+		t.Errorf("sheet parsed, want a header error")
+	}
+	expectReported(t, parser.Reporter, "multiple primary key columns: id, name")
+}
+
+func TestCheckRefs(t *testing.T) {
+	path := writeWorkbook(t, "drop.xlsx",
+		testSheet{"item", [][]interface{}{
+			{"编号", "名称"},
+			{"*id", "name"},
+			{"int", "string"},
+			{"cs", "cs"},
+			{"1001", "木剑"},
+			{"1002", "铁剑"},
+		}},
+		testSheet{"drop", [][]interface{}{
+			{"编号", "道具", "道具列表", "奖励", "材料", "商店", "价格"},
+			{"*id", "item", "items", "reward.item", "costs[0]", "shop", "price"},
+			{"int", "ref<item.id>", "ref<item.id>[]", "ref<item.id>", "ref<item.id>", "ref<shop.id>", "ref<item.price>"},
+			{"cs", "cs", "cs", "cs", "cs", "cs", "cs"},
+			{"1", "1001", "1001,1002", "1002", "1001", "", ""},
+			{"2", "1005", "1002,1006", "1007", "1008", "1", "1"},
+			{"3", "", "", "", "", "2", "2"},
+		}},
+	)
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectNoErrors(t, parser.Reporter)
+	CheckRefs(sheets, parser.Reporter)
+	expectReported(t, parser.Reporter,
+		`drop.xlsx!drop!B6 (field "item", type ref<item.id>): item.id 1005 does not exist`,
+		`drop.xlsx!drop!C6 (field "items", type ref<item.id>[]): element 1: item.id 1006 does not exist`,
+		`drop.xlsx!drop!D6 (field "reward.item", type ref<item.id>): item.id 1007 does not exist`,
+		`drop.xlsx!drop!E6 (field "costs[0]", type ref<item.id>): item.id 1008 does not exist`,
+		// 引用的表或字段不存在时每列只报告一次，定位到类型所在的单元格
+		`drop.xlsx!drop!F3 (field "shop", type ref<shop.id>): referenced table "shop" not found`,
+		`drop.xlsx!drop!G3 (field "price", type ref<item.price>): referenced field "price" not found in table item`,
+	)
+	if parser.Reporter.Count() != 6 {
+		t.Errorf("reported %d error(s), want 6:\n%s", parser.Reporter.Count(), reported(parser.Reporter))
+	}
+	if sheets[0].Failed || !sheets[1].Failed {
+		t.Errorf("failed = %v, %v, want false, true", sheets[0].Failed, sheets[1].Failed)
+	}
+}
+
+// TestParseNestedRefs 嵌套在map、多维数组以及自定义类型中的引用无法校验，读取表头时报错
+func TestParseNestedRefs(t *testing.T) {
+	path := writeWorkbook(t, "drop.xlsx",
+		testSheet{"#types", [][]interface{}{
+			{"类型", "注释"},
+			{"Cost{item:ref<item.id>,count:int}", "消耗"},
+		}},
+		testSheet{"drop", [][]interface{}{
+			{"编号", "道具", "道具列表"},
+			{"id", "items", "groups"},
+			{"int", "map<int,ref<item.id>>", "ref<item.id>[][]"},
+			{"cs", "cs", "cs"},
+		}},
+	)
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := LoadStructs(f, path, "#types", parser.Reporter); ok {
+		t.Errorf("LoadStructs succeeded, want an error")
+	}
+	if data := parseWorkbook(t, parser, path)[0]; data != nil {
+		t.Errorf("sheet parsed, want a header error")
+	}
+	expectReported(t, parser.Reporter,
+		`drop.xlsx!#types!A2 (field "type", type string): type Cost: field item has type ref<item.id>, references are not supported in custom types`,
+		`drop.xlsx!drop!B3 (field "items", type map<int,ref<item.id>>): reference ref<item.id> inside map<int,ref<item.id>> is not supported`,
+		`drop.xlsx!drop!C3 (field "groups", type ref<item.id>[][]): reference ref<item.id> inside ref<item.id>[][] is not supported`,
+	)
+}
+
+func TestParseDefaults(t *testing.T) {
+	path := writeWorkbook(t, "hero.xlsx",
+		testSheet{"hero", [][]interface{}{
+			{"编号", "等级", "称号", "标签"},
+			{"id", "lv", "title", "tags"},
+			{"int", "int=1", "string = 无", "int[]=1,2"},
+			{"cs", "cs", "cs", "cs"},
+			{"1", "5", "勇者", "3"},
+			{"2", "", "", ""},
+		}},
+		testSheet{"invalid", [][]interface{}{
+			{"编号", "等级", "经验"},
+			{"id", "lv", "exp"},
+			{"int", "int=abc", "int?=0"},
+			{"cs", "cs", "cs"},
+		}},
+	)
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectReported(t, parser.Reporter,
+		`hero.xlsx!invalid!B3 (field "lv", type int): default value: "abc" is not an integer`,
+		`hero.xlsx!invalid!C3 (field "exp", type int): type int? can not have a default value`,
+	)
+	if got, want := parser.Reporter.Count(), 2; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	// 空单元格使用默认值
+	want := `[{"id":1,"lv":5,"tags":[3],"title":"勇者"},{"id":2,"lv":1,"tags":[1,2],"title":"无"}]`
+	if got := exportJson(t, sheets[0]); got != want {
+		t.Errorf("hero = %s, want %s", got, want)
+	}
+	if sheets[1] != nil {
+		t.Errorf("invalid sheet parsed, want a header error")
+	}
+}
+
+func TestParseMarkers(t *testing.T) {
+	path := writeWorkbook(t, "hero.xlsx", testSheet{"hero", [][]interface{}{
+		{"编号", "等级", "称号", "经验"},
+		{"id", "lv", "title", "exp"},
+		{"int", "int?", "string!", "int"},
+		{"cs", "cs", "cs", "cs"},
+		{"1", "5", "勇者", "10"},
+		{"2", "", "勇者", ""},
+		{"3", "1", "", "1"},
+	}})
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	data := parseWorkbook(t, parser, path)[0]
+	expectReported(t, parser.Reporter, `hero.xlsx!hero!C7 (field "title", type string): value is required`)
+	if got, want := parser.Reporter.Count(), 1; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	if !data.Failed {
+		t.Errorf("sheet should fail")
+	}
+	// int?为空时输出null，没有标记的字段为空时不输出
+	want := `[{"exp":10,"id":1,"lv":5,"title":"勇者"},{"id":2,"lv":null,"title":"勇者"},{"exp":1,"id":3,"lv":1}]`
+	if got := exportJson(t, data); got != want {
+		t.Errorf("hero = %s, want %s", got, want)
+	}
+}
+
+func TestParseConstraints(t *testing.T) {
+	path := writeWorkbook(t, "hero.xlsx",
+		testSheet{"hero", [][]interface{}{
+			{"编号", "等级", "代码", "品质", "技能"},
+			{"id", "lv", "code", "quality", "skills"},
+			{"int", "int(1..100)", "string(/^[a-z]+$/)", "string{a,b}", "int[](1..10)"},
+			{"cs", "cs", "cs", "cs", "cs"},
+			{"1", "5", "abc", "a", "1,2"},
+			{"2", "101", "ABC", "c", "1,20"},
+		}},
+		testSheet{"invalid", [][]interface{}{
+			{"编号", "等级", "经验"},
+			{"id", "lv", "exp"},
+			{"int", "string(1..2)", "int(1..10)=20"},
+			{"cs", "cs", "cs"},
+		}},
+	)
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectReported(t, parser.Reporter,
+		`hero.xlsx!hero!B6 (field "lv", type int): 101 is out of range 1..100`,
+		`hero.xlsx!hero!C6 (field "code", type string): "ABC" does not match /^[a-z]+$/`,
+		`hero.xlsx!hero!D6 (field "quality", type string): c is not one of {a,b}`,
+		`hero.xlsx!hero!E6 (field "skills", type int[]): element 1: 20 is out of range 1..10`,
+		`hero.xlsx!invalid!B3 (field "lv", type string): range constraint "1..2" is not supported for type string`,
+		`hero.xlsx!invalid!C3 (field "exp", type int): default value: 20 is out of range 1..10`,
+	)
+	if got, want := parser.Reporter.Count(), 6; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	if !sheets[0].Failed || sheets[1] != nil {
+		t.Errorf("hero should fail and invalid should have a header error")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"excel-tools/report"
+	"excel-tools/types"
+	"github.com/xuri/excelize/v2"
+	"strings"
+)
+
+// typesSheetPrefix 自定义类型sheet的前缀，例如 #types、#types_reward
+const typesSheetPrefix = "#types"
+
+// IsTypesSheet sheet是否为自定义类型sheet
+func IsTypesSheet(sheet string) bool {
+	return strings.HasPrefix(sheet, typesSheetPrefix)
+}
+
+// LoadStructs 读取自定义类型sheet，第一行为表头，之后每行依次为 类型声明、注释，例如 Reward{itemId:int,count:int}。存在错误时返回false
+func LoadStructs(f *excelize.File, file string, sheet string, reporter *report.Reporter) ([]*types.Struct, bool) {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		reporter.Add(file, sheet, err)
+		return nil, false
+	}
+	var structs []*types.Struct
+	ok := true
+	for rowIndex, row := range rows {
+		if rowIndex == 0 || len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		s, err := types.ParseStruct(row[0])
+		if err != nil {
+			reporter.Add(file, sheet, &report.CellError{
+				File: file, Sheet: sheet, Col: 0, Row: rowIndex, Field: "type", Type: "string", Err: err,
+			})
+			ok = false
+			continue
+		}
+		if len(row) > 1 {
+			s.Comment = strings.TrimSpace(row[1])
+		}
+		structs = append(structs, s)
+	}
+	return structs, ok
+}
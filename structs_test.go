@@ -0,0 +1,61 @@
+package main
+
+import (
+	"excel-tools/report"
+	"excel-tools/types"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestLoadStructs(t *testing.T) {
+	path := writeWorkbook(t, "types.xlsx", testSheet{"#types", [][]interface{}{
+		{"类型", "注释"},
+		{"Reward{itemId:int,count:int}", "奖励"},
+		{""},
+		{"Cost{item:int,item:long}"},
+		{"Box{reward:Reward}"},
+	}})
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reporter := &report.Reporter{}
+	structs, ok := LoadStructs(f, path, "#types", reporter)
+	if ok {
+		t.Errorf("LoadStructs succeeded, want errors")
+	}
+	expectReported(t, reporter,
+		`types.xlsx!#types!A4 (field "type", type string): type Cost: duplicate field item`,
+		`types.xlsx!#types!A5 (field "type", type string): type Box: field reward has type Reward, expect int, long, float, number, bool, string, date or enum`,
+	)
+	if len(structs) != 1 || structs[0].Name != "Reward" || structs[0].Comment != "奖励" || len(structs[0].Fields) != 2 {
+		t.Errorf("structs = %v, want Reward", structs)
+	}
+}
+
+func TestParseStructColumns(t *testing.T) {
+	header := [][]interface{}{
+		{"编号", "奖励", "奖励列表"},
+		{"id", "reward", "rewards"},
+		{"int", "Reward", "Reward[]"},
+		{"cs", "cs", "cs"},
+	}
+	path := writeWorkbook(t, "item.xlsx",
+		testSheet{"item", append(header[:4:4], []interface{}{"1", "1001:5", `[{"itemId": 1001, "count": 5}]`})},
+		testSheet{"bad", append(header[:4:4], []interface{}{"1", "1001", "1001:5,1002"})},
+	)
+	reward, err := types.ParseStruct("Reward{itemId:int,count:int}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), &types.TypeFactory{Structs: map[string]*types.Struct{"Reward": reward}})
+	sheets := parseWorkbook(t, parser, path)
+	expectReported(t, parser.Reporter,
+		`item.xlsx!bad!B5 (field "reward", type Reward): "1001" is not a Reward, expect itemId:count`,
+		`item.xlsx!bad!C5 (field "rewards", type Reward[]): element 1: "1002" is not a Reward, expect itemId:count`,
+	)
+	if got, want := exportJson(t, sheets[0]), `[{"id":1,"reward":{"count":5,"itemId":1001},"rewards":[{"count":5,"itemId":1001}]}]`; got != want {
+		t.Errorf("item = %s, want %s", got, want)
+	}
+}
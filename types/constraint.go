@@ -0,0 +1,211 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Constraint 列的取值约束，在类型转换之后校验，数组按元素校验
+type Constraint interface {
+	Check(value interface{}) error
+}
+
+// ParseConstraint 解析类型后的约束，例如 int(1..100)、string(/^[a-z_]+$/)、int{1,2,5}，
+// 返回去掉约束的类型以及约束内容(1..100、/^[a-z_]+$/、{1,2,5})，没有约束时返回false
+func ParseConstraint(types string) (form string, text string, ok bool) {
+	if strings.HasSuffix(types, ")") {
+		if i := strings.Index(types, "("); i > 0 {
+			return strings.TrimSpace(types[:i]), strings.TrimSpace(types[i+1 : len(types)-1]), true
+		}
+	} else if strings.HasSuffix(types, "}") {
+		if i := strings.Index(types, "{"); i > 0 {
+			return strings.TrimSpace(types[:i]), strings.TrimSpace(types[i:]), true
+		}
+	}
+	return types, "", false
+}
+
+// constraintKinds 每种约束支持的元素类型
+var constraintKinds = map[string]map[string]bool{
+	"range":   {"int": true, "long": true, "float": true, "number": true},
+	"pattern": {"string": true, "date": true},
+	"set":     {"int": true, "long": true, "float": true, "number": true, "string": true, "date": true, "enum": true},
+}
+
+// GetConstraint 按列的类型创建约束，数组的约束作用于每个元素
+//
+// 约束的格式为 min..max(可以省略一侧)、/正则表达式/、{a,b,c}，集合中的值按元素类型转换后比较
+func (f *TypeFactory) GetConstraint(types string, text string) (Constraint, error) {
+	elem := BaseType(types)
+	if e, ok := ParseArray(elem); ok {
+		elem = BaseType(e)
+	}
+	var kind string
+	switch {
+	case len(text) >= 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/"):
+		kind = "pattern"
+	case strings.HasPrefix(text, "{") && strings.HasSuffix(text, "}"):
+		kind = "set"
+	case strings.Contains(text, ".."):
+		kind = "range"
+	default:
+		return nil, fmt.Errorf("invalid constraint %q, expect min..max, /regex/ or {a,b,c}", text)
+	}
+	if !constraintKinds[kind][elem] {
+		return nil, fmt.Errorf("%s constraint %q is not supported for type %s", kind, text, types)
+	}
+	var (
+		constraint Constraint
+		err        error
+	)
+	switch kind {
+	case "pattern":
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(text[1 : len(text)-1]); err == nil {
+			constraint = &PatternConstraint{Pattern: re}
+		}
+	case "set":
+		constraint, err = f.newSetConstraint(types, text)
+	default:
+		constraint, err = newRangeConstraint(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint %q: %w", text, err)
+	}
+	return constraint, nil
+}
+
+// checkEach 数组按元素校验，错误信息包含元素下标，空值(int?)不校验
+func checkEach(value interface{}, check func(interface{}) error) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for i, item := range v {
+			if err := check(item); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		return nil
+	default:
+		return check(value)
+	}
+}
+
+// RangeConstraint 数值范围，包含两端，Min/Max为nil表示不限制
+type RangeConstraint struct {
+	Min  *float64
+	Max  *float64
+	Text string
+}
+
+// newRangeConstraint 解析 min..max
+func newRangeConstraint(text string) (*RangeConstraint, error) {
+	bounds := strings.SplitN(text, "..", 2)
+	c := &RangeConstraint{Text: text}
+	for i, bound := range bounds {
+		bound = strings.TrimSpace(bound)
+		if bound == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", bound)
+		}
+		if i == 0 {
+			c.Min = &v
+		} else {
+			c.Max = &v
+		}
+	}
+	if c.Min == nil && c.Max == nil {
+		return nil, fmt.Errorf("range has no bound")
+	}
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return nil, fmt.Errorf("min is greater than max")
+	}
+	return c, nil
+}
+
+// Check 校验数值是否在范围内
+func (c *RangeConstraint) Check(value interface{}) error {
+	return checkEach(value, c.check)
+}
+
+func (c *RangeConstraint) check(value interface{}) error {
+	var v float64
+	switch n := value.(type) {
+	case int:
+		v = float64(n)
+	case int64:
+		v = float64(n)
+	case float32:
+		v = float64(n)
+	case float64:
+		v = n
+	default:
+		return fmt.Errorf("%v is not a number", value)
+	}
+	if c.Min != nil && v < *c.Min || c.Max != nil && v > *c.Max {
+		return fmt.Errorf("%v is out of range %s", value, c.Text)
+	}
+	return nil
+}
+
+// PatternConstraint 正则表达式
+type PatternConstraint struct {
+	Pattern *regexp.Regexp
+}
+
+// Check 校验字符串是否匹配正则表达式
+func (c *PatternConstraint) Check(value interface{}) error {
+	return checkEach(value, c.check)
+}
+
+func (c *PatternConstraint) check(value interface{}) error {
+	if s := fmt.Sprint(value); !c.Pattern.MatchString(s) {
+		return fmt.Errorf("%q does not match /%s/", s, c.Pattern)
+	}
+	return nil
+}
+
+// SetConstraint 允许的取值集合
+type SetConstraint struct {
+	// 按元素类型转换后的值
+	Values []interface{}
+	Text   string
+	keys   map[string]bool
+}
+
+// newSetConstraint 解析 {a,b,c}，每个值按元素类型转换
+func (f *TypeFactory) newSetConstraint(types string, text string) (*SetConstraint, error) {
+	elem := types
+	if e, ok := ParseArray(BaseType(types)); ok {
+		elem = e
+	}
+	converter := f.GetConvert(elem)
+	c := &SetConstraint{Text: text, keys: make(map[string]bool)}
+	for _, item := range strings.Split(text[1:len(text)-1], ",") {
+		v, err := converter.Handle(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		c.Values = append(c.Values, v)
+		c.keys[fmt.Sprint(v)] = true
+	}
+	return c, nil
+}
+
+// Check 校验值是否在集合中
+func (c *SetConstraint) Check(value interface{}) error {
+	return checkEach(value, c.check)
+}
+
+func (c *SetConstraint) check(value interface{}) error {
+	if !c.keys[fmt.Sprint(value)] {
+		return fmt.Errorf("%v is not one of %s", value, c.Text)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package types
+
+import "testing"
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		types string
+		form  string
+		text  string
+		ok    bool
+	}{
+		{"int", "int", "", false},
+		{"int(1..100)", "int", "1..100", true},
+		{"float ( ..0.5 )", "float", "..0.5", true},
+		{"string(/^[a-z_]+$/)", "string", "/^[a-z_]+$/", true},
+		{"int{1,2,5}", "int", "{1,2,5}", true},
+		{"int[](0..)", "int[]", "0..", true},
+		{"enum<Quality>{Common,Epic}", "enum<Quality>", "{Common,Epic}", true},
+		{"(1..2)", "(1..2)", "", false},
+	}
+	for _, tt := range tests {
+		form, text, ok := ParseConstraint(tt.types)
+		if form != tt.form || text != tt.text || ok != tt.ok {
+			t.Errorf("ParseConstraint(%q) = %q, %q, %v, want %q, %q, %v", tt.types, form, text, ok, tt.form, tt.text, tt.ok)
+		}
+	}
+}
+
+func TestGetConstraint(t *testing.T) {
+	f := &TypeFactory{
+		Enums: map[string]*Enum{"Quality": {Name: "Quality", Values: []*EnumValue{{Name: "Common", Value: 1}, {Name: "Epic", Value: 4}}}},
+	}
+	tests := []struct {
+		types string
+		text  string
+		// 约束创建成功时依次校验的值以及是否通过
+		values []interface{}
+		pass   []bool
+		ok     bool
+	}{
+		{"int", "1..100", []interface{}{1, 100, 0, 101, nil}, []bool{true, true, false, false, true}, true},
+		{"long", "..0", []interface{}{int64(-5000000000), int64(1)}, []bool{true, false}, true},
+		{"float", "0.5..", []interface{}{float32(0.5), float32(0.25)}, []bool{true, false}, true},
+		{"int[]", "0..10", []interface{}{[]interface{}{0, 10}, []interface{}{1, 11}}, []bool{true, false}, true},
+		{"string", "/^[a-z_]+$/", []interface{}{"sword", "sword_1", "Sword", ""}, []bool{true, false, false, false}, true},
+		{"int", "{1,2,5}", []interface{}{5, 3}, []bool{true, false}, true},
+		{"string", "{a, b}", []interface{}{"b", "c"}, []bool{true, false}, true},
+		{"enum<Quality>", "{Epic}", []interface{}{4, 1}, []bool{true, false}, true},
+		{"enum<Quality>[]", "{Common,Epic}", []interface{}{[]interface{}{1, 4}}, []bool{true}, true},
+		{"ref<Item.id>", "1000..1999", []interface{}{1001, 2001}, []bool{true, false}, true},
+		{"int", "abc", nil, nil, false},
+		{"int", "100..1", nil, nil, false},
+		{"int", "..", nil, nil, false},
+		{"int", "a..b", nil, nil, false},
+		{"int", "/^1$/", nil, nil, false},
+		{"string", "1..2", nil, nil, false},
+		{"bool", "{true}", nil, nil, false},
+		{"string", "/[a-/", nil, nil, false},
+		{"int", "{1,a}", nil, nil, false},
+		{"enum<Quality>", "{Rare}", nil, nil, false},
+	}
+	for _, tt := range tests {
+		c, err := f.GetConstraint(tt.types, tt.text)
+		if (err == nil) != tt.ok {
+			t.Errorf("GetConstraint(%q, %q) error = %v, want ok %v", tt.types, tt.text, err, tt.ok)
+			continue
+		}
+		for i, value := range tt.values {
+			if err := c.Check(value); (err == nil) != tt.pass[i] {
+				t.Errorf("%s%s: Check(%v) error = %v, want pass %v", tt.types, tt.text, value, err, tt.pass[i])
+			}
+		}
+	}
+}
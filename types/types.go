@@ -1,86 +1,140 @@
 package types
 
 import (
+	"errors"
 	"excel-tools/convert"
 	"excel-tools/util"
+	"fmt"
 	"github.com/tidwall/gjson"
+	"math"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// TypeConverter 类型转换器，单元格的值无法转换时返回错误
 type TypeConverter interface {
-	Handle(string) interface{}
+	Handle(string) (interface{}, error)
+}
+
+// parseInt 转换整型，错误信息面向配表人员
+func parseInt(value string) (int, error) {
+	v, err := convert.Str2IntE(value)
+	if err != nil {
+		return 0, numberError(value, "an integer", err)
+	}
+	return v, nil
+}
+
+// parseLong 转换长整型
+func parseLong(value string) (int64, error) {
+	v, err := convert.Str2Int64E(value)
+	if err != nil {
+		return 0, numberError(value, "a long integer", err)
+	}
+	return v, nil
+}
+
+// parseFloat 转换浮点型，NaN和Inf无法导出为JSON，按非数字处理
+func parseFloat(value string) (float32, error) {
+	v, err := convert.Str2Float32E(value)
+	if err != nil {
+		return 0, numberError(value, "a number", err)
+	}
+	if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	return v, nil
+}
+
+// numberError 区分数值越界和格式错误
+func numberError(value string, kind string, err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) && numErr.Err == strconv.ErrRange {
+		return fmt.Errorf("%q is out of range for %s", value, kind)
+	}
+	return fmt.Errorf("%q is not %s", value, kind)
 }
 
 type NumberTypeConvert struct{}
 
 // Handle 数字类型转换
-func (*NumberTypeConvert) Handle(value string) interface{} {
+func (*NumberTypeConvert) Handle(value string) (interface{}, error) {
 	if !util.IsNumber(value) {
-		panic("invalid number type for value: " + value)
+		return nil, fmt.Errorf("%q is not a number", value)
 	}
 	if util.IsInt(value) {
-		return convert.Str2Int(value)
+		return parseInt(value)
 	} else if util.IsDec(value) {
-		return convert.Str2Float32(value)
+		return parseFloat(value)
 	}
-	return value
+	return value, nil
 }
 
 type IntTypeConvert struct{}
 
 // Handle 数字类型转换
-func (*IntTypeConvert) Handle(value string) interface{} {
-	return convert.Str2Int(value)
+func (*IntTypeConvert) Handle(value string) (interface{}, error) {
+	return parseInt(value)
 }
 
 type FloatTypeConvert struct{}
 
 // Handle 数字类型转换
-func (*FloatTypeConvert) Handle(value string) interface{} {
-	return convert.Str2Float32(value)
+func (*FloatTypeConvert) Handle(value string) (interface{}, error) {
+	return parseFloat(value)
 }
 
 type LongTypeConvert struct{}
 
 // Handle 数字类型转换
-func (*LongTypeConvert) Handle(value string) interface{} {
-	return convert.Str2Int64(value)
+func (*LongTypeConvert) Handle(value string) (interface{}, error) {
+	return parseLong(value)
 }
 
 type BoolTypeConverter struct{}
 
 // Handle 整型数据转换
-func (*BoolTypeConverter) Handle(value string) interface{} {
-	return convert.Str2Bool(value)
+func (*BoolTypeConverter) Handle(value string) (interface{}, error) {
+	v, err := convert.Str2BoolE(value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a bool, expect true/false or 1/0", value)
+	}
+	return v, nil
 }
 
 type StringTypeConverter struct{}
 
 // Handle 字符串转换
-func (*StringTypeConverter) Handle(value string) interface{} {
-	return value
+func (*StringTypeConverter) Handle(value string) (interface{}, error) {
+	return value, nil
 }
 
 type DateTypeConverter struct{}
 
 // Handle 布尔转换
-func (*DateTypeConverter) Handle(value string) interface{} {
-	return util.FormatTimeString(value)
+func (*DateTypeConverter) Handle(value string) (interface{}, error) {
+	date := util.FormatTimeString(value)
+	if _, err := time.ParseInLocation(util.TimeFormat, date, time.Local); err != nil {
+		return nil, fmt.Errorf("%q is not a date, expect format like 2021-12-12 00:00:00", value)
+	}
+	return date, nil
 }
 
 type ObjectTypeConverter struct{}
 
 // Handle 对象转换
-func (*ObjectTypeConverter) Handle(value string) interface{} {
+func (*ObjectTypeConverter) Handle(value string) (interface{}, error) {
 	if gjson.Valid(value) {
 		parse := gjson.Parse(value)
 		if parse.IsObject() {
-			return parse.Value()
+			return parse.Value(), nil
 		}
-		panic("Invalid value for json object: " + value)
+		return nil, fmt.Errorf("%q is not a JSON object", value)
 	} else if strings.TrimSpace(value) == "" {
 		values := make(map[string]interface{})
-		return values
+		return values, nil
 	} else {
 		// 特殊处理：10001:100,10002:200
 		arr := strings.Split(value, ",")
@@ -88,27 +142,31 @@ func (*ObjectTypeConverter) Handle(value string) interface{} {
 		for _, str := range arr {
 			v := strings.Split(str, ":")
 			if len(v) < 2 || v[0] == "" || v[1] == "" {
-				panic("瞎配数据，也不检查报错了吧:) " + value)
+				return nil, fmt.Errorf("%q is not a JSON object or key:value list, bad entry %q", value, str)
+			}
+			num, err := parseInt(v[1])
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", v[0], err)
 			}
-			values[v[0]] = convert.Str2Int(v[1])
+			values[v[0]] = num
 		}
-		return values
+		return values, nil
 	}
 }
 
 type ObjectStringTypeConverter struct{}
 
 // Handle 对象转换
-func (*ObjectStringTypeConverter) Handle(value string) interface{} {
+func (*ObjectStringTypeConverter) Handle(value string) (interface{}, error) {
 	if gjson.Valid(value) {
 		parse := gjson.Parse(value)
 		if parse.IsObject() {
-			return parse.Value()
+			return parse.Value(), nil
 		}
-		panic("Invalid value for json object: " + value)
+		return nil, fmt.Errorf("%q is not a JSON object", value)
 	} else if strings.TrimSpace(value) == "" {
 		values := make(map[string]interface{})
-		return values
+		return values, nil
 	} else {
 		// 特殊处理：10001:100,10002:200
 		arr := strings.Split(value, ",")
@@ -116,120 +174,610 @@ func (*ObjectStringTypeConverter) Handle(value string) interface{} {
 		for _, str := range arr {
 			v := strings.Split(str, ":")
 			if len(v) < 2 {
-				panic("Invalid value for object: " + value)
+				return nil, fmt.Errorf("%q is not a JSON object or key:value list, bad entry %q", value, str)
 			}
 			values[v[0]] = v[1]
 		}
-		return values
+		return values, nil
 	}
 }
 
 type ArrayTypeConverter struct{}
 
 // Handle 数组转换
-func (*ArrayTypeConverter) Handle(value string) interface{} {
+func (*ArrayTypeConverter) Handle(value string) (interface{}, error) {
 	// 以标准方式：[1001, 1002]
 	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") && gjson.Valid(value) {
 		parse := gjson.Parse(value)
 		if parse.IsArray() {
-			return parse.Array()
+			return parse.Value(), nil
 		}
-		panic("Invalid value for json array: " + value)
+		return nil, fmt.Errorf("%q is not a JSON array", value)
 	} else if strings.TrimSpace(value) == "" {
 		// 空字符串则直接返回空数组即可
 		result := make([]interface{}, 0)
-		return result
-	} else if !strings.ContainsAny(value, ",") {
-		// 单个方式: 10010
-		result := make([]interface{}, 0)
-		numCvt := new(NumberTypeConvert)
-		if util.IsNumber(value) {
-			result = append(result, numCvt.Handle(value))
-		} else if len(value) > 0 {
-			result = append(result, value)
-		}
-		return result
+		return result, nil
 	} else {
+		// 单个方式: 10010
 		// 多个方式：10001, 10002, 1003
 		// 如果不是合法的json array格式则采用 123,456,789这种字符串分隔符的方式进行处理
 		arr := strings.Split(value, `,`)
 		result := make([]interface{}, 0)
 		numCvt := new(NumberTypeConvert)
-		for _, str := range arr {
+		for i, str := range arr {
 			if util.IsNumber(str) {
-				result = append(result, numCvt.Handle(str))
+				num, err := numCvt.Handle(str)
+				if err != nil {
+					return nil, fmt.Errorf("element %d: %w", i, err)
+				}
+				result = append(result, num)
 			} else if len(str) > 0 {
 				result = append(result, str)
 			}
 		}
-		return result
+		return result, nil
 	}
 }
 
-type PairTypeConverter struct{}
+type TypedArrayConverter struct {
+	// 元素类型的转换器
+	Elem TypeConverter
+}
 
-// Handle 键值转换
-func (*PairTypeConverter) Handle(value string) interface{} {
-	if gjson.Valid(value) {
-		parse := gjson.Parse(value)
-		if parse.IsObject() {
-			return parse.Value()
+// Handle 指定元素类型的数组转换，每个元素都使用元素类型的转换器转换，例如 int[]、string[]
+func (c *TypedArrayConverter) Handle(value string) (interface{}, error) {
+	result := make([]interface{}, 0)
+	var elements []string
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+		// 以标准方式：[1001, 1002]
+		if !gjson.Valid(trimmed) {
+			return nil, fmt.Errorf("%q is not a valid JSON array", value)
 		}
-		panic("Invalid value for json object: " + value)
-	} else if strings.TrimSpace(value) == "" {
-		values := make(map[string]interface{})
-		return values
-	} else {
-		// 特殊处理：10001:100,10002:200 -> Pair(10010, 100)
-		arr := strings.Split(value, ",")
-		values := make(map[string]interface{})
-		for _, str := range arr {
-			v := strings.Split(str, ":")
-			if len(v) < 2 {
-				panic("Invalid value for object: " + value)
+		for _, item := range gjson.Parse(trimmed).Array() {
+			if item.Type == gjson.String {
+				elements = append(elements, item.Str)
+			} else {
+				elements = append(elements, item.Raw)
 			}
-			values["x"] = convert.Str2Int(v[0])
-			values["y"] = convert.Str2Int(v[1])
 		}
-		return values
+	} else if strings.TrimSpace(value) != "" {
+		// 逗号分隔方式：1001,1002
+		for _, element := range strings.Split(value, ",") {
+			elements = append(elements, strings.TrimSpace(element))
+		}
+	}
+	for i, element := range elements {
+		v, err := c.Elem.Handle(element)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		result = append(result, v)
 	}
+	return result, nil
+}
+
+type PairTypeConverter struct{}
+
+// Handle 键值转换，只能填写一组 x:y，多组需要使用 pair[]
+func (*PairTypeConverter) Handle(value string) (interface{}, error) {
+	// 特殊处理：10001:100 -> Pair(10010, 100)
+	return parseTuple(value, "pair", "x", "y")
 }
 
 type TripleTypeConverter struct{}
 
-// Handle 三键值转换
-func (*TripleTypeConverter) Handle(value string) interface{} {
-	if gjson.Valid(value) {
+// Handle 三键值转换，只能填写一组 x:y:z，多组需要使用 triple[]
+func (*TripleTypeConverter) Handle(value string) (interface{}, error) {
+	// 特殊处理：10001:100:1 -> Triple(10010, 100, 1)
+	return parseTuple(value, "triple", "x", "y", "z")
+}
+
+// parseTuple 转换pair/triple，支持JSON对象以及冒号分隔的方式，两种方式都必须正好包含所有的键并且值为整数
+func parseTuple(value string, kind string, keys ...string) (interface{}, error) {
+	values := make(map[string]interface{})
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "{") {
+		// 以标准方式：{"x": 10001, "y": 100}
+		if !gjson.Valid(trimmed) {
+			return nil, fmt.Errorf("%q is not a valid JSON object", value)
+		}
+		var err error
+		gjson.Parse(trimmed).ForEach(func(k, v gjson.Result) bool {
+			key, known := k.String(), false
+			for _, name := range keys {
+				known = known || name == key
+			}
+			if !known {
+				err = fmt.Errorf("unknown key %q in %s, expect %s", key, kind, strings.Join(keys, ", "))
+				return false
+			}
+			if v.Type != gjson.Number {
+				err = fmt.Errorf("%s: %s is not an integer", key, v.Raw)
+				return false
+			}
+			if values[key], err = parseInt(v.Raw); err != nil {
+				err = fmt.Errorf("%s: %w", key, err)
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if _, ok := values[key]; !ok {
+				return nil, fmt.Errorf("missing key %s of %s", key, kind)
+			}
+		}
+		return values, nil
+	} else if trimmed == "" {
+		return values, nil
+	}
+	if entries := strings.Split(value, ","); len(entries) > 1 {
+		return nil, fmt.Errorf("%q has %d entries, a %s holds only one, use %s[] for a list", value, len(entries), kind, kind)
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != len(keys) {
+		return nil, fmt.Errorf("%q is not a %s, expect %s", value, kind, strings.Join(keys, ":"))
+	}
+	if err := putInts(values, parts, keys...); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// putInts 将分隔后的各段依次按整型写入对应的键
+func putInts(values map[string]interface{}, parts []string, keys ...string) error {
+	for i, key := range keys {
+		num, err := parseInt(strings.TrimSpace(parts[i]))
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		values[key] = num
+	}
+	return nil
+}
+
+// ParseRef 解析引用类型，例如 ref<Item.id> 或 ref<Item.id>[]，返回引用的表和字段
+func ParseRef(types string) (table string, field string, array bool, ok bool) {
+	if strings.HasSuffix(types, "[]") {
+		types, array = strings.TrimSuffix(types, "[]"), true
+	}
+	if !strings.HasPrefix(types, "ref<") || !strings.HasSuffix(types, ">") {
+		return "", "", false, false
+	}
+	target := strings.TrimSpace(types[len("ref<") : len(types)-1])
+	dot := strings.LastIndex(target, ".")
+	if dot <= 0 || dot == len(target)-1 {
+		return "", "", false, false
+	}
+	return target[:dot], target[dot+1:], array, true
+}
+
+// BaseType 类型对应的基础类型，引用类型按整型处理，枚举类型返回enum，其它类型返回自身
+func BaseType(types string) string {
+	if _, _, array, ok := ParseRef(types); ok {
+		if array {
+			return "int[]"
+		}
+		return "int"
+	}
+	if _, ok := ParseEnum(types); ok {
+		return "enum"
+	}
+	return types
+}
+
+// mapKeyTypes map<K,V>支持的键类型
+var mapKeyTypes = map[string]bool{"int": true, "long": true, "string": true}
+
+// ParseMap 解析 map<K,V> 类型，返回键和值的类型，例如 map<int,float>、map<string,int[]>
+func ParseMap(types string) (key string, value string, ok bool) {
+	if !strings.HasPrefix(types, "map<") || !strings.HasSuffix(types, ">") {
+		return "", "", false
+	}
+	parts := splitTop(types[len("map<"):len(types)-1], ',', "<>")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	key, value = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	return key, value, key != "" && value != ""
+}
+
+// splitTop 按分隔符拆分，忽略括号内的分隔符，brackets为成对的左右括号，例如 "<>[]{}"
+func splitTop(s string, sep rune, brackets string) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for i, c := range s {
+		if idx := strings.IndexRune(brackets, c); idx >= 0 {
+			if idx%2 == 0 {
+				depth++
+			} else if depth > 0 {
+				depth--
+			}
+		} else if c == sep && depth == 0 {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// ParseDefault 解析带默认值的类型，例如 int=0、string=无，括号内的等号不作为分隔符，没有默认值时返回false
+func ParseDefault(types string) (form string, value string, ok bool) {
+	parts := splitTop(types, '=', "(){}[]")
+	if len(parts) < 2 {
+		return types, "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(types[len(parts[0])+1:]), true
+}
+
+// ParseMarker 解析类型末尾的标记，int? 表示空单元格导出为null，int! 表示单元格不能为空，没有标记时返回0
+func ParseMarker(types string) (form string, marker byte) {
+	if strings.HasSuffix(types, "?") || strings.HasSuffix(types, "!") {
+		return strings.TrimSpace(types[:len(types)-1]), types[len(types)-1]
+	}
+	return types, 0
+}
+
+// ParseArray 解析指定元素类型的数组，例如 int[]、pair[]，返回元素类型
+func ParseArray(types string) (elem string, ok bool) {
+	if !strings.HasSuffix(types, "[]") {
+		return "", false
+	}
+	elem = strings.TrimSpace(strings.TrimSuffix(types, "[]"))
+	return elem, elem != ""
+}
+
+// Walk 依次访问类型以及其中嵌套的类型，例如 map<string,enum<Quality>> 会访问map本身、键类型以及值类型
+func Walk(types string, fn func(string)) {
+	fn(types)
+	if key, value, ok := ParseMap(types); ok {
+		Walk(key, fn)
+		Walk(value, fn)
+	} else if elem, ok := ParseArray(types); ok {
+		Walk(elem, fn)
+	}
+}
+
+type MapTypeConverter struct {
+	// 键和值的转换器
+	Key   TypeConverter
+	Value TypeConverter
+}
+
+// Handle map<K,V>转换，支持JSON对象以及 k:v,k:v 的方式，键和值分别按各自的类型转换
+func (c *MapTypeConverter) Handle(value string) (interface{}, error) {
+	values := make(map[string]interface{})
+	// 键和值的原始文本
+	var entries [][2]string
+	if strings.HasPrefix(strings.TrimSpace(value), "{") && gjson.Valid(value) {
+		// 以标准方式：{"1001": 1.5}
 		parse := gjson.Parse(value)
-		if parse.IsObject() {
-			return parse.Value()
+		if !parse.IsObject() {
+			return nil, fmt.Errorf("%q is not a JSON object", value)
 		}
-		panic("Invalid value for json object: " + value)
-	} else if strings.TrimSpace(value) == "" {
-		values := make(map[string]interface{})
-		return values
-	} else {
-		// 特殊处理：10001:100:1,10002:200:1 -> Triple(10010, 100, 1)
-		arr := strings.Split(value, ",")
-		values := make(map[string]interface{})
-		for _, str := range arr {
-			v := strings.Split(str, ":")
-			if len(v) < 3 {
-				panic("Invalid value for object: " + value)
+		parse.ForEach(func(k, v gjson.Result) bool {
+			raw := v.Raw
+			if v.Type == gjson.String {
+				raw = v.Str
+			}
+			entries = append(entries, [2]string{k.String(), raw})
+			return true
+		})
+	} else if strings.TrimSpace(value) != "" {
+		// 特殊处理：1001:1.5,1002:2，值为数组时可以使用 a:[1,2],b:[3]
+		for _, entry := range splitTop(value, ',', "[]{}") {
+			kv := strings.SplitN(entry, ":", 2)
+			if len(kv) < 2 || strings.TrimSpace(kv[0]) == "" {
+				return nil, fmt.Errorf("%q is not a JSON object or key:value list, bad entry %q", value, entry)
+			}
+			entries = append(entries, [2]string{strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])})
+		}
+	}
+	for _, entry := range entries {
+		key, err := c.Key.Handle(entry[0])
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry[0], err)
+		}
+		// 键按转换后的值输出，例如 001 输出为 1
+		k := fmt.Sprint(key)
+		if _, ok := values[k]; ok {
+			return nil, fmt.Errorf("duplicate key %q", entry[0])
+		}
+		v, err := c.Value.Handle(entry[1])
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry[0], err)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+// Enum 枚举定义，由枚举定义sheet声明
+type Enum struct {
+	// 枚举名称，类型行中以 enum<Name> 引用
+	Name string
+	// 枚举值，按定义顺序
+	Values []*EnumValue
+}
+
+// EnumValue 枚举值
+type EnumValue struct {
+	Name    string
+	Value   int
+	Comment string
+}
+
+// Find 按名称查找枚举值，也可以直接填写已定义的数值
+func (e *Enum) Find(value string) (*EnumValue, bool) {
+	for _, v := range e.Values {
+		if v.Name == value {
+			return v, true
+		}
+	}
+	if num, err := convert.Str2IntE(value); err == nil {
+		for _, v := range e.Values {
+			if v.Value == num {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Names 所有枚举值的名称
+func (e *Enum) Names() []string {
+	names := make([]string, 0, len(e.Values))
+	for _, v := range e.Values {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// ParseEnum 解析枚举类型，例如 enum<Quality>，返回枚举名称
+func ParseEnum(types string) (name string, ok bool) {
+	if !strings.HasPrefix(types, "enum<") || !strings.HasSuffix(types, ">") {
+		return "", false
+	}
+	name = strings.TrimSpace(types[len("enum<") : len(types)-1])
+	return name, name != ""
+}
+
+type EnumTypeConverter struct {
+	// 枚举定义，未定义时为空
+	Enum *Enum
+	// 是否导出枚举名称而不是数值
+	ByName bool
+}
+
+// Handle 枚举转换，单元格填写枚举名称，导出数值或者名称
+func (c *EnumTypeConverter) Handle(value string) (interface{}, error) {
+	if c.Enum == nil {
+		return nil, errors.New("enum is not defined")
+	}
+	v, ok := c.Enum.Find(strings.TrimSpace(value))
+	if !ok {
+		return nil, fmt.Errorf("%q is not a value of enum %s, expect one of %s", value, c.Enum.Name, strings.Join(c.Enum.Names(), ", "))
+	}
+	if c.ByName {
+		return v.Name, nil
+	}
+	return v.Value, nil
+}
+
+// structDecl 自定义结构体的声明，例如 Reward{itemId:int,count:int,weight:float}
+var structDecl = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*\{(.*)\}\s*$`)
+
+// structFieldTypes 结构体字段支持的类型，只支持标量以便使用 a:b:c 的方式填写
+var structFieldTypes = map[string]bool{
+	"int": true, "long": true, "float": true, "number": true, "bool": true, "string": true, "date": true, "enum": true,
+}
+
+// builtinTypes 内置类型名称，自定义结构体不能使用
+var builtinTypes = map[string]bool{
+	"number": true, "int": true, "float": true, "long": true, "bool": true, "date": true, "object": true,
+	"array": true, "string": true, "pair": true, "triple": true,
+}
+
+// Struct 自定义结构体类型，在 #types sheet 或者配置文件中声明
+type Struct struct {
+	Name   string
+	Fields []*StructField
+	// 注释
+	Comment string
+	// 是否由点号分隔的字段名组成，例如 reward.item，字段可以是任意类型，空单元格对应的字段会被省略
+	Nested bool
+}
+
+// StructField 结构体字段
+type StructField struct {
+	Name string
+	Type string
+	// 空单元格是否导出为null，只用于点号分隔的字段名生成的结构体
+	Nullable bool
+}
+
+// ParseStruct 解析结构体声明，例如 Reward{itemId:int,count:int,weight:float}
+func ParseStruct(decl string) (*Struct, error) {
+	match := structDecl.FindStringSubmatch(decl)
+	if match == nil {
+		return nil, fmt.Errorf("%q is not a valid type declaration, expect Name{field:type,...}", decl)
+	}
+	s := &Struct{Name: match[1]}
+	if builtinTypes[s.Name] {
+		return nil, fmt.Errorf("type name %s is a builtin type", s.Name)
+	}
+	for _, part := range strings.Split(match[2], ",") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) < 2 {
+			return nil, fmt.Errorf("type %s: bad field %q, expect field:type", s.Name, strings.TrimSpace(part))
+		}
+		field := &StructField{Name: strings.TrimSpace(kv[0]), Type: strings.TrimSpace(kv[1])}
+		if field.Name == "" {
+			return nil, fmt.Errorf("type %s: field name is empty", s.Name)
+		}
+		if _, ok := s.Field(field.Name); ok {
+			return nil, fmt.Errorf("type %s: duplicate field %s", s.Name, field.Name)
+		}
+		// 引用按列校验，结构体字段中的引用无法校验
+		if _, _, _, ok := ParseRef(field.Type); ok {
+			return nil, fmt.Errorf("type %s: field %s has type %s, references are not supported in custom types", s.Name, field.Name, field.Type)
+		}
+		if !structFieldTypes[BaseType(field.Type)] {
+			return nil, fmt.Errorf("type %s: field %s has type %s, expect int, long, float, number, bool, string, date or enum", s.Name, field.Name, field.Type)
+		}
+		s.Fields = append(s.Fields, field)
+	}
+	return s, nil
+}
+
+// Field 按名称查找字段
+func (s *Struct) Field(name string) (*StructField, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+// Shorthand 冒号分隔的填写方式，例如 itemId:count:weight
+func (s *Struct) Shorthand() string {
+	names := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		names = append(names, field.Name)
+	}
+	return strings.Join(names, ":")
+}
+
+type StructTypeConverter struct {
+	Struct *Struct
+	// 每个字段的转换器，和Struct.Fields一一对应
+	Fields []TypeConverter
+}
+
+// Handle 结构体转换，支持JSON对象以及按字段顺序冒号分隔的方式，例如 1001:5:0.5，所有字段都必须填写
+func (c *StructTypeConverter) Handle(value string) (interface{}, error) {
+	values := make(map[string]interface{})
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "{") {
+		// 以标准方式：{"itemId": 1001, "count": 5, "weight": 0.5}
+		if !gjson.Valid(trimmed) {
+			return nil, fmt.Errorf("%q is not a valid JSON object", value)
+		}
+		members := make(map[string]gjson.Result)
+		var err error
+		gjson.Parse(trimmed).ForEach(func(k, v gjson.Result) bool {
+			if _, ok := c.Struct.Field(k.String()); !ok {
+				err = fmt.Errorf("unknown field %q in type %s", k.String(), c.Struct.Name)
+				return false
+			}
+			members[k.String()] = v
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i, field := range c.Struct.Fields {
+			v, ok := members[field.Name]
+			if !ok {
+				return nil, fmt.Errorf("missing field %s of type %s", field.Name, c.Struct.Name)
+			}
+			raw := v.Raw
+			if v.Type == gjson.String {
+				raw = v.Str
+			}
+			if values[field.Name], err = c.Fields[i].Handle(raw); err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
 			}
-			values["x"] = convert.Str2Int(v[0])
-			values["y"] = convert.Str2Int(v[1])
-			values["z"] = convert.Str2Int(v[2])
 		}
-		return values
+		return values, nil
 	}
+	if entries := strings.Split(value, ","); len(entries) > 1 {
+		return nil, fmt.Errorf("%q has %d entries, a %s holds only one, use %s[] for a list", value, len(entries), c.Struct.Name, c.Struct.Name)
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != len(c.Struct.Fields) {
+		return nil, fmt.Errorf("%q is not a %s, expect %s", value, c.Struct.Name, c.Struct.Shorthand())
+	}
+	for i, field := range c.Struct.Fields {
+		v, err := c.Fields[i].Handle(strings.TrimSpace(parts[i]))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		values[field.Name] = v
+	}
+	return values, nil
 }
 
 type TypeFactory struct {
+	// 所有枚举定义，键为枚举名称
+	Enums map[string]*Enum
+	// 枚举类型是否导出名称而不是数值
+	EnumName bool
+	// 所有自定义结构体，键为类型名称
+	Structs map[string]*Struct
+}
+
+// Check 检查类型行中的类型是否合法，包括嵌套的类型，例如枚举是否已定义、map的键类型
+func (f *TypeFactory) Check(types string) error {
+	var err error
+	Walk(types, func(t string) {
+		if err != nil {
+			return
+		}
+		if t == "" {
+			err = errors.New("type is empty")
+		} else if _, ok := ParseArray(t); ok {
+			// 数组的元素类型由Walk单独检查，例如 map<int,int>[] 的元素 map<int,int>
+			return
+		} else if name, ok := ParseEnum(t); ok {
+			if f.Enums[name] == nil {
+				err = fmt.Errorf("enum %s is not defined", name)
+			}
+		} else if strings.HasPrefix(t, "map<") && t != "map<string>" {
+			if key, _, ok := ParseMap(t); !ok {
+				err = fmt.Errorf("type %s is not a valid map, expect map<K,V>", t)
+			} else if !mapKeyTypes[key] {
+				err = fmt.Errorf("map key type %s is not supported, expect int, long or string", key)
+			}
+		} else if _, _, _, ok := ParseRef(t); ok {
+			// 引用按列校验，嵌套在map或者多维数组中的引用无法校验
+			if _, _, _, top := ParseRef(types); !top {
+				err = fmt.Errorf("reference %s inside %s is not supported, expect ref<Table.field> or ref<Table.field>[]", t, types)
+			}
+		} else if !builtinTypes[t] && t != "map<string>" && f.Structs[t] == nil {
+			err = fmt.Errorf("unknown type %s", t)
+		}
+	})
+	return err
 }
 
 // GetConvert 根据类型获取对应的类型转换器
-func (*TypeFactory) GetConvert(types string) (conv TypeConverter) {
+func (f *TypeFactory) GetConvert(types string) (conv TypeConverter) {
+	if _, _, _, ok := ParseRef(types); ok {
+		// 引用和整型的转换方式一致，引用是否存在在所有表加载完成后校验
+		return f.GetConvert(BaseType(types))
+	}
+	if name, ok := ParseEnum(types); ok {
+		return &EnumTypeConverter{Enum: f.Enums[name], ByName: f.EnumName}
+	}
+	if key, value, ok := ParseMap(types); ok {
+		return &MapTypeConverter{Key: f.GetConvert(key), Value: f.GetConvert(value)}
+	}
+	if s, ok := f.Structs[types]; ok {
+		conv := &StructTypeConverter{Struct: s}
+		for _, field := range s.Fields {
+			conv.Fields = append(conv.Fields, f.GetConvert(field.Type))
+		}
+		return conv
+	}
+	if elem, ok := ParseArray(types); ok {
+		// 指定元素类型的数组，例如 int[]、pair[]，每个元素使用元素类型的转换器
+		return &TypedArrayConverter{Elem: f.GetConvert(elem)}
+	}
 	switch types {
 	case "number":
 		conv = new(NumberTypeConvert)
@@ -253,14 +801,20 @@ func (*TypeFactory) GetConvert(types string) (conv TypeConverter) {
 		conv = new(PairTypeConverter)
 	case "triple":
 		conv = new(TripleTypeConverter)
-	case "int[]":
-		conv = new(ArrayTypeConverter)
-	case "string[]":
-		conv = new(ArrayTypeConverter)
 	case "map<string>":
 		conv = new(ObjectStringTypeConverter)
 	default:
-		conv = new(StringTypeConverter)
+		conv = &UnknownTypeConverter{Type: types}
 	}
 	return
 }
+
+// UnknownTypeConverter 未知类型，类型行经过Check校验后不会出现，转换时返回错误而不是按字符串导出
+type UnknownTypeConverter struct {
+	Type string
+}
+
+// Handle 返回未知类型的错误
+func (c *UnknownTypeConverter) Handle(string) (interface{}, error) {
+	return nil, fmt.Errorf("unknown type %s", c.Type)
+}
@@ -0,0 +1,387 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// convertCase 类型转换的测试用例，ok为false时期望返回错误
+type convertCase struct {
+	in   string
+	want interface{}
+	ok   bool
+}
+
+// checkConvert 按用例依次调用转换器
+func checkConvert(t *testing.T, name string, conv TypeConverter, tests []convertCase) {
+	t.Helper()
+	for _, tt := range tests {
+		got, err := conv.Handle(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s(%q) error = %v, want ok %v", name, tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s(%q) = %#v, want %#v", name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFloatTypeConvert(t *testing.T) {
+	checkConvert(t, "float", new(FloatTypeConvert), []convertCase{
+		{"1.5", float32(1.5), true},
+		{"-2", float32(-2), true},
+		{"1e3", float32(1000), true},
+		{"NaN", nil, false},
+		{"nan", nil, false},
+		{"Inf", nil, false},
+		{"-Inf", nil, false},
+		{"infinity", nil, false},
+		{"1e39", nil, false},
+		{"1.5a", nil, false},
+	})
+}
+
+func TestPairTypeConverter(t *testing.T) {
+	checkConvert(t, "pair", new(PairTypeConverter), []convertCase{
+		{"10001:100", map[string]interface{}{"x": 10001, "y": 100}, true},
+		{" 1 : -2 ", map[string]interface{}{"x": 1, "y": -2}, true},
+		{`{"x": 1, "y": 2}`, map[string]interface{}{"x": 1, "y": 2}, true},
+		{"", map[string]interface{}{}, true},
+		{"1:2:3", nil, false},
+		{"1", nil, false},
+		{"1:a", nil, false},
+		{"1:2,3:4", nil, false},
+		{`{"foo": "bar"}`, nil, false},
+		{`{"x": 1}`, nil, false},
+		{`{"x": 1, "y": 2, "z": 3}`, nil, false},
+		{`{"x": 1, "y": "2"}`, nil, false},
+		{`{"x": 1, "y": 2.5}`, nil, false},
+		{`{"x": 1,`, nil, false},
+	})
+}
+
+func TestTripleTypeConverter(t *testing.T) {
+	checkConvert(t, "triple", new(TripleTypeConverter), []convertCase{
+		{"1:2:3", map[string]interface{}{"x": 1, "y": 2, "z": 3}, true},
+		{`{"z": 3, "y": 2, "x": 1}`, map[string]interface{}{"x": 1, "y": 2, "z": 3}, true},
+		{"1:2", nil, false},
+		{"1:2:3:4", nil, false},
+		{`{"x": 1, "y": 2}`, nil, false},
+		{`{"x": 1, "y": 2, "w": 3}`, nil, false},
+	})
+}
+
+// TestArrayTypeConverter JSON数组导出为普通的数组，而不是gjson.Result的内部结构
+func TestArrayTypeConverter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"[1001, 1002]", `[1001,1002]`},
+		{`[1, "a", [2, 3], {"b": true}]`, `[1,"a",[2,3],{"b":true}]`},
+		{"[]", `[]`},
+		{"1001,1002", `[1001,1002]`},
+		{"1001,a", `[1001,"a"]`},
+		{"", `[]`},
+	}
+	conv := new(ArrayTypeConverter)
+	for _, tt := range tests {
+		got, err := conv.Handle(tt.in)
+		if err != nil {
+			t.Errorf("array(%q) error = %v", tt.in, err)
+			continue
+		}
+		data, err := json.Marshal(got)
+		if err != nil {
+			t.Errorf("array(%q) marshal error = %v", tt.in, err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("array(%q) = %s, want %s", tt.in, data, tt.want)
+		}
+	}
+}
+
+func TestTypeFactoryCheck(t *testing.T) {
+	f := &TypeFactory{
+		Enums:   map[string]*Enum{"Quality": {Name: "Quality"}},
+		Structs: map[string]*Struct{"Reward": {Name: "Reward"}},
+	}
+	tests := []struct {
+		types string
+		ok    bool
+	}{
+		{"int", true},
+		{"string", true},
+		{"date", true},
+		{"array", true},
+		{"object", true},
+		{"map<string>", true},
+		{"pair[]", true},
+		{"int[][]", true},
+		{"enum<Quality>", true},
+		{"enum<Quality>[]", true},
+		{"ref<Item.id>", true},
+		{"ref<Item.id>[]", true},
+		{"map<int,Reward>", true},
+		{"map<int,float>[]", true},
+		{"map<string,int[]>[]", true},
+		{"Reward", true},
+		{"Reward[]", true},
+		{"", false},
+		{"itn", false},
+		{"Rewrd[]", false},
+		{"enum", false},
+		{"enum<Color>", false},
+		{"ref<Item>", false},
+		{"map<float,int>", false},
+		{"map<int,itn>", false},
+		{"map<float,int>[]", false},
+		{"map<int,ref<Item.id>>", false},
+		{"map<string,ref<Item.id>[]>", false},
+		{"ref<Item.id>[][]", false},
+	}
+	for _, tt := range tests {
+		if err := f.Check(tt.types); (err == nil) != tt.ok {
+			t.Errorf("Check(%q) error = %v, want ok %v", tt.types, err, tt.ok)
+		}
+	}
+}
+
+func TestUnknownTypeConverter(t *testing.T) {
+	f := &TypeFactory{}
+	if _, err := f.GetConvert("itn").Handle("1"); err == nil {
+		t.Errorf("GetConvert(%q) converted the value, want an error", "itn")
+	}
+}
+
+func TestParseDefault(t *testing.T) {
+	tests := []struct {
+		types string
+		form  string
+		value string
+		ok    bool
+	}{
+		{"int", "int", "", false},
+		{"int=0", "int", "0", true},
+		{"string = 无", "string", "无", true},
+		{"string=a=b", "string", "a=b", true},
+		{"int(1..100)=5", "int(1..100)", "5", true},
+		{"string(/^a=b$/)", "string(/^a=b$/)", "", false},
+		{"string{a=1,b}=a=1", "string{a=1,b}", "a=1", true},
+		{"int=", "int", "", true},
+	}
+	for _, tt := range tests {
+		form, value, ok := ParseDefault(tt.types)
+		if form != tt.form || value != tt.value || ok != tt.ok {
+			t.Errorf("ParseDefault(%q) = %q, %q, %v, want %q, %q, %v", tt.types, form, value, ok, tt.form, tt.value, tt.ok)
+		}
+	}
+}
+
+func TestParseMarker(t *testing.T) {
+	tests := []struct {
+		types  string
+		form   string
+		marker byte
+	}{
+		{"int", "int", 0},
+		{"int?", "int", '?'},
+		{"string!", "string", '!'},
+		{"int[] ?", "int[]", '?'},
+		{"map<int,int>!", "map<int,int>", '!'},
+	}
+	for _, tt := range tests {
+		form, marker := ParseMarker(tt.types)
+		if form != tt.form || marker != tt.marker {
+			t.Errorf("ParseMarker(%q) = %q, %q, want %q, %q", tt.types, form, marker, tt.form, tt.marker)
+		}
+	}
+}
+
+func TestParseMap(t *testing.T) {
+	tests := []struct {
+		types string
+		key   string
+		value string
+		ok    bool
+	}{
+		{"map<int,float>", "int", "float", true},
+		{"map< string , int[] >", "string", "int[]", true},
+		{"map<int,map<string,int>>", "int", "map<string,int>", true},
+		{"map<long,enum<Quality>>", "long", "enum<Quality>", true},
+		{"map<string>", "", "", false},
+		{"map<int,>", "", "", false},
+		{"map<int,int,int>", "", "", false},
+		{"int", "", "", false},
+	}
+	for _, tt := range tests {
+		key, value, ok := ParseMap(tt.types)
+		if ok != tt.ok || ok && (key != tt.key || value != tt.value) {
+			t.Errorf("ParseMap(%q) = %q, %q, %v, want %q, %q, %v", tt.types, key, value, ok, tt.key, tt.value, tt.ok)
+		}
+	}
+}
+
+func TestTypedArrayConverter(t *testing.T) {
+	f := &TypeFactory{
+		Enums: map[string]*Enum{"Quality": {Name: "Quality", Values: []*EnumValue{{Name: "Common", Value: 1}, {Name: "Epic", Value: 4}}}},
+	}
+	checkConvert(t, "int[]", f.GetConvert("int[]"), []convertCase{
+		{"1001,1002", []interface{}{1001, 1002}, true},
+		{" 1 , -2 ", []interface{}{1, -2}, true},
+		{"[1001, 1002]", []interface{}{1001, 1002}, true},
+		{"", []interface{}{}, true},
+		{"[]", []interface{}{}, true},
+		{"1,a", nil, false},
+		{"1.5", nil, false},
+		{"0x10", nil, false},
+		{"[1, 2", nil, false},
+	})
+	checkConvert(t, "long[]", f.GetConvert("long[]"), []convertCase{
+		{"-5000000000,1", []interface{}{int64(-5000000000), int64(1)}, true},
+	})
+	checkConvert(t, "float[]", f.GetConvert("float[]"), []convertCase{
+		{"1.5,-2", []interface{}{float32(1.5), float32(-2)}, true},
+	})
+	checkConvert(t, "string[]", f.GetConvert("string[]"), []convertCase{
+		{`["a,b", "c"]`, []interface{}{"a,b", "c"}, true},
+		{"a, b", []interface{}{"a", "b"}, true},
+	})
+	checkConvert(t, "enum<Quality>[]", f.GetConvert("enum<Quality>[]"), []convertCase{
+		{"Common,Epic,4", []interface{}{1, 4, 4}, true},
+		{"Common,Rare", nil, false},
+	})
+}
+
+func TestTupleArrayConverter(t *testing.T) {
+	f := &TypeFactory{}
+	checkConvert(t, "pair[]", f.GetConvert("pair[]"), []convertCase{
+		{`[{"x": 1, "y": 2}, {"x": 3, "y": 4}]`, []interface{}{
+			map[string]interface{}{"x": 1, "y": 2},
+			map[string]interface{}{"x": 3, "y": 4},
+		}, true},
+		{"1:2,3:4", []interface{}{
+			map[string]interface{}{"x": 1, "y": 2},
+			map[string]interface{}{"x": 3, "y": 4},
+		}, true},
+		{"1:2,3", nil, false},
+	})
+	checkConvert(t, "triple[]", f.GetConvert("triple[]"), []convertCase{
+		{"1:2:3,4:5:6", []interface{}{
+			map[string]interface{}{"x": 1, "y": 2, "z": 3},
+			map[string]interface{}{"x": 4, "y": 5, "z": 6},
+		}, true},
+		{"", []interface{}{}, true},
+		{"1:2:3,4:5", nil, false},
+	})
+}
+
+func TestMapTypeConverter(t *testing.T) {
+	f := &TypeFactory{
+		Enums: map[string]*Enum{"Quality": {Name: "Quality", Values: []*EnumValue{{Name: "Common", Value: 1}}}},
+	}
+	checkConvert(t, "map<int,float>", f.GetConvert("map<int,float>"), []convertCase{
+		{"1001:1.5,1002:2", map[string]interface{}{"1001": float32(1.5), "1002": float32(2)}, true},
+		{`{"1001": 1.5, "-1": 2}`, map[string]interface{}{"1001": float32(1.5), "-1": float32(2)}, true},
+		{"001:1", map[string]interface{}{"1": float32(1)}, true},
+		{"", map[string]interface{}{}, true},
+		{"1:1,01:2", nil, false},
+		{"a:1", nil, false},
+		{"1:a", nil, false},
+		{"1", nil, false},
+	})
+	checkConvert(t, "map<string,int[]>", f.GetConvert("map<string,int[]>"), []convertCase{
+		{"a:[1,2],b:[3]", map[string]interface{}{"a": []interface{}{1, 2}, "b": []interface{}{3}}, true},
+		{`{"a": [1, 2]}`, map[string]interface{}{"a": []interface{}{1, 2}}, true},
+		{"a:[1,x]", nil, false},
+	})
+	checkConvert(t, "map<int,int>[]", f.GetConvert("map<int,int>[]"), []convertCase{
+		{`[{"1": 2}, {"3": 4}]`, []interface{}{map[string]interface{}{"1": 2}, map[string]interface{}{"3": 4}}, true},
+		{`[{"a": 2}]`, nil, false},
+	})
+	checkConvert(t, "map<long,enum<Quality>>", f.GetConvert("map<long,enum<Quality>>"), []convertCase{
+		{"5000000000:Common", map[string]interface{}{"5000000000": 1}, true},
+		{"1:Epic", nil, false},
+	})
+}
+
+func TestParseStruct(t *testing.T) {
+	tests := []struct {
+		decl   string
+		name   string
+		fields []*StructField
+		ok     bool
+	}{
+		{"Reward{itemId:int,count:int,weight:float}", "Reward", []*StructField{
+			{Name: "itemId", Type: "int"}, {Name: "count", Type: "int"}, {Name: "weight", Type: "float"},
+		}, true},
+		{" Cost { item : enum<Quality> , at : date } ", "Cost", []*StructField{
+			{Name: "item", Type: "enum<Quality>"}, {Name: "at", Type: "date"},
+		}, true},
+		{"Reward", "", nil, false},
+		{"pair{x:int}", "", nil, false},
+		{"Reward{itemId}", "", nil, false},
+		{"Reward{:int}", "", nil, false},
+		{"Reward{a:int,a:long}", "", nil, false},
+		{"Reward{a:int[]}", "", nil, false},
+		{"Reward{a:pair}", "", nil, false},
+		{"Reward{a:ref<Item.id>}", "", nil, false},
+	}
+	for _, tt := range tests {
+		s, err := ParseStruct(tt.decl)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseStruct(%q) error = %v, want ok %v", tt.decl, err, tt.ok)
+			continue
+		}
+		if tt.ok && (s.Name != tt.name || !reflect.DeepEqual(s.Fields, tt.fields)) {
+			t.Errorf("ParseStruct(%q) = %s %v, want %s %v", tt.decl, s.Name, s.Fields, tt.name, tt.fields)
+		}
+	}
+}
+
+func TestStructTypeConverter(t *testing.T) {
+	reward, err := ParseStruct("Reward{itemId:int,count:long,weight:float}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &TypeFactory{Structs: map[string]*Struct{"Reward": reward}}
+	want := map[string]interface{}{"itemId": 1001, "count": int64(5), "weight": float32(0.5)}
+	checkConvert(t, "Reward", f.GetConvert("Reward"), []convertCase{
+		{"1001:5:0.5", want, true},
+		{" 1001 : 5 : 0.5 ", want, true},
+		{`{"itemId": 1001, "count": 5, "weight": 0.5}`, want, true},
+		{`{"itemId": "1001", "count": "5", "weight": "0.5"}`, want, true},
+		{"1001:5", nil, false},
+		{"1001:5:0.5:1", nil, false},
+		{"1001:5:0.5,1002:1:1", nil, false},
+		{"1001:a:0.5", nil, false},
+		{`{"itemId": 1001, "count": 5}`, nil, false},
+		{`{"itemId": 1001, "count": 5, "weight": 0.5, "extra": 1}`, nil, false},
+		{`{"itemId": 1001,`, nil, false},
+	})
+	checkConvert(t, "Reward[]", f.GetConvert("Reward[]"), []convertCase{
+		{"1001:5:0.5,1001:5:0.5", []interface{}{want, want}, true},
+		{"1001:5:0.5,1002", nil, false},
+	})
+}
+
+func TestEnumTypeConverter(t *testing.T) {
+	quality := &Enum{Name: "Quality", Values: []*EnumValue{{Name: "Common", Value: 1}, {Name: "Epic", Value: 4}}}
+	checkConvert(t, "enum<Quality>", &EnumTypeConverter{Enum: quality}, []convertCase{
+		{"Epic", 4, true},
+		{" Common ", 1, true},
+		{"4", 4, true},
+		{"2", nil, false},
+		{"epic", nil, false},
+	})
+	checkConvert(t, "enum<Quality> by name", &EnumTypeConverter{Enum: quality, ByName: true}, []convertCase{
+		{"Epic", "Epic", true},
+		{"1", "Common", true},
+	})
+	checkConvert(t, "undefined enum", &EnumTypeConverter{}, []convertCase{
+		{"Epic", nil, false},
+	})
+}
@@ -140,6 +140,19 @@ func ConvertToFormatDay(excelDaysString string) string {
 	return resultTime
 }
 
+// CamelCase 转换为首字母大写的驼峰命名，例如 item_shop -> ItemShop，defaultPig -> DefaultPig
+func CamelCase(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' ' || r == '.'
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(part[1:])
+	}
+	return b.String()
+}
+
 // MemberInArray 元素是否在数组内
 func MemberInArray(target string, array []string) bool {
 	sort.Strings(array)
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"excel-tools/report"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"strings"
+)
+
+// verticalSheetPrefix 纵向键值表sheet的前缀，例如 @global 导出为 global
+const verticalSheetPrefix = "@"
+
+// verticalColumns 纵向键值表每一行依次为 字段名、类型、值、输出端、注释
+var verticalColumns = map[string]int{"name": 0, "type": 1, "value": 2, "out": 3, "note": 4}
+
+// IsVerticalSheet sheet是否为纵向的键值表，sheet名称以@开头或者在sheets中配置了 vertical: true
+func (c *Conf) IsVerticalSheet(file string, sheet string) bool {
+	return strings.HasPrefix(sheet, verticalSheetPrefix) || c.GetSheetConf(file, sheet).Vertical
+}
+
+// ParseVertical 解析纵向的键值表，例如全局常量，第一行为表头，之后每一行为一个字段，整个sheet导出为一个对象
+//
+// 类型支持和横向sheet相同的写法，例如 int=0、int?、int(1..100)，字段名支持 a.b 以及 a[0] 组成嵌套的对象和数组。
+// 输出端为空时客户端和服务端都输出
+func (p *SheetParser) ParseVertical(f *excelize.File, file string, sheet string) *SheetData {
+	reporter := p.Reporter
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		fmt.Printf("read sheet %s failed\r\n", sheet)
+		reporter.Add(file, sheet, err)
+		return nil
+	}
+
+	data := &SheetData{
+		File:    file,
+		Sheet:   sheet,
+		Name:    p.Conf.TableName(file, sheet),
+		Columns: make(map[string]map[string]bool),
+	}
+	// 表头是否存在错误
+	invalid := false
+	// 字段树，点号分隔的字段名组成嵌套的对象，带下标的字段名组成数组
+	tree := newFieldTree()
+	// 每一行转换后的值
+	converted := make(map[int]interface{})
+	for rowIndex, row := range rows {
+		if rowIndex == 0 {
+			continue
+		}
+		cell := func(role string) string {
+			col, ok := verticalColumns[role]
+			if !ok || col >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[col])
+		}
+		// 字段名以#开头表示忽略该行，字段名和类型都为空的行也忽略
+		if strings.HasPrefix(cell("name"), "#") || cell("name") == "" && cell("type") == "" {
+			continue
+		}
+		column, errs := p.ParseColumn(cell)
+		name, form := column.Field.Name, column.Field.Type
+		cellError := func(role string, err error) {
+			reporter.Add(file, sheet, &report.CellError{
+				File: file, Sheet: sheet, Col: verticalColumns[role], Row: rowIndex,
+				Field: name, Type: form, Err: err,
+			})
+		}
+		// 输出端为空时客户端和服务端都输出
+		out := cell("out")
+		if out == "" {
+			out = "cs"
+		}
+		errs = append(errs, p.AddColumn(tree, column, rowIndex, out, true)...)
+		if len(errs) > 0 {
+			for _, e := range errs {
+				cellError(e.Role, e.Err)
+			}
+			invalid = true
+			continue
+		}
+		data.Columns[name] = make(map[string]bool)
+
+		// 类型转换并校验取值约束，空单元格按默认值以及 int?/int! 处理
+		value, ok, err := p.Convert(column, cell("value"))
+		if err != nil {
+			cellError("value", err)
+			data.Failed = true
+			continue
+		}
+		if !ok {
+			continue
+		}
+		converted[rowIndex] = value
+		if value == nil {
+			continue
+		}
+		// 记录字段的值以及引用单元格
+		data.Record(column.Field, value, verticalColumns["value"], rowIndex, verticalColumns["type"], rowIndex)
+	}
+
+	if invalid {
+		return nil
+	}
+	if err := p.BuildFields(data, tree); err != nil {
+		reporter.Add(file, sheet, err)
+		return nil
+	}
+
+	// 数组中间的空元素会导致之后的元素错位
+	if path, leaf, ok := tree.Gap(converted); ok {
+		reporter.Add(file, sheet, &report.CellError{
+			File: file, Sheet: sheet, Col: verticalColumns["value"], Row: leaf.col,
+			Field: leaf.path, Type: leaf.field.Type, Err: fmt.Errorf("array element %s is empty but a later element is filled", path),
+		})
+		data.Failed = true
+	}
+
+	// 整个sheet导出为一个对象
+	data.Options.Single, data.Options.Keyed = true, false
+	client, server := tree.Rows(converted)
+	if len(data.ClientFields) > 0 {
+		data.Clients = append(data.Clients, client)
+	}
+	if len(data.ServerFields) > 0 {
+		data.Servers = append(data.Servers, server)
+	}
+	return data
+}
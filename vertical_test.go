@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestParseVertical(t *testing.T) {
+	path := writeWorkbook(t, "global.xlsx",
+		testSheet{"@global", [][]interface{}{
+			{"name", "type", "value", "out", "comment"},
+			{"maxLevel", "int", "100", "", "最大等级"},
+			{"#ignored", "int", "1", "", ""},
+			{"secret", "string", "abc", "s", ""},
+			{"reward.item", "int", "1001", "", ""},
+			{"reward.count", "int=1", "", "", ""},
+			{"tags[0]", "string", "a", "c", ""},
+			{"tags[1]", "string", "b", "c", ""},
+			{"rate", "float?", "", "", ""},
+		}},
+		testSheet{"@invalid", [][]interface{}{
+			{"name", "type", "value", "out", "comment"},
+			{"*id", "int", "1", "", ""},
+			{"lv", "int(1..10)", "20", "", ""},
+		}},
+	)
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectReported(t, parser.Reporter,
+		`global.xlsx!@invalid!A2 (field "id", type int): primary key "id" is not supported in a vertical sheet`,
+		`global.xlsx!@invalid!C3 (field "lv", type int): 20 is out of range 1..10`,
+	)
+	if got, want := parser.Reporter.Count(), 2; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	data := sheets[0]
+	if data.Name != "global" {
+		t.Errorf("name = %s, want global", data.Name)
+	}
+	// 整个sheet导出为一个对象，输出端为空时两端都输出
+	want := `{"maxLevel":100,"rate":null,"reward":{"count":1,"item":1001},"secret":"abc"}`
+	if got := exportJson(t, data); got != want {
+		t.Errorf("global = %s, want %s", got, want)
+	}
+	if len(data.Clients) != 1 || len(data.Clients[0]) != 4 {
+		t.Errorf("client = %v, want maxLevel, reward, tags and rate", data.Clients)
+	}
+	if sheets[1] != nil {
+		t.Errorf("invalid sheet parsed, want a header error")
+	}
+}
+
+// TestCheckVerticalRefs 纵向sheet的类型都在同一列，每一行的引用错误都要报告
+func TestCheckVerticalRefs(t *testing.T) {
+	path := writeWorkbook(t, "global.xlsx",
+		testSheet{"item", [][]interface{}{
+			{"编号"},
+			{"*id"},
+			{"int"},
+			{"cs"},
+			{"1001"},
+		}},
+		testSheet{"@global", [][]interface{}{
+			{"name", "type", "value", "out", "comment"},
+			{"shop", "ref<shop.id>", "1", "", ""},
+			{"price", "ref<item.price>", "2", "", ""},
+			{"item", "ref<item.id>", "1001", "", ""},
+			{"items", "ref<item.id>[]", "1001,1002", "", ""},
+		}},
+	)
+	parser := testParser(testConf(t, "config: {output: {format: json}}"), nil)
+	sheets := parseWorkbook(t, parser, path)
+	expectNoErrors(t, parser.Reporter)
+	CheckRefs(sheets, parser.Reporter)
+	expectReported(t, parser.Reporter,
+		`global.xlsx!@global!B2 (field "shop", type ref<shop.id>): referenced table "shop" not found`,
+		`global.xlsx!@global!B3 (field "price", type ref<item.price>): referenced field "price" not found in table item`,
+		`global.xlsx!@global!C5 (field "items", type ref<item.id>[]): element 1: item.id 1002 does not exist`,
+	)
+	if got, want := parser.Reporter.Count(), 3; got != want {
+		t.Errorf("reported %d error(s), want %d:\n%s", got, want, reported(parser.Reporter))
+	}
+	if sheets[0].Failed || !sheets[1].Failed {
+		t.Errorf("failed = %v, %v, want false, true", sheets[0].Failed, sheets[1].Failed)
+	}
+}